@@ -0,0 +1,328 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: aggregate.go
+*  Description: Group-by aggregation of CSV rows.
+ */
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//  Returned for an aggregate other than Count without a column.
+var ErrorNoAggColumn = errors.New("Aggregate needs a column.")
+
+//  An aggregate function.
+type AggFunc int
+
+const (
+	Count         AggFunc = iota // Number of rows, or non-NULL fields.
+	Sum                          // Sum of numeric fields.
+	Min                          // Minimum numeric field.
+	Max                          // Maximum numeric field.
+	Mean                         // Mean of numeric fields.
+	CountDistinct                // Number of distinct non-NULL fields.
+	First                        // First field of the group.
+	Last                         // Last field of the group.
+)
+
+var aggFuncNames = []string{"count", "sum", "min", "max", "mean", "count_distinct", "first", "last"}
+
+func (f AggFunc) String() string {
+	if f < 0 || int(f) >= len(aggFuncNames) {
+		return "AggFunc(" + strconv.Itoa(int(f)) + ")"
+	}
+	return aggFuncNames[f]
+}
+
+//  An aggregate computed for each group. Fields that the Reader's Config
+//  considers NULL are ignored by every function except First and Last.
+type Agg struct {
+	Func   AggFunc
+	Column string // Aggregated column. Count counts rows when empty.
+	Name   string // Output column name. Defaults to "func_column".
+}
+
+//  Returns the output column name of the aggregate.
+func (a Agg) OutputName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if a.Column == "" {
+		return a.Func.String()
+	}
+	return a.Func.String() + "_" + a.Column
+}
+
+//  Aggregate the rows of r, grouped by the values of the groupBy columns,
+//  in a single pass holding every group in a hash table. Writes a header
+//  of the groupBy columns and aggregate names with w, followed by a row
+//  for each group in the order groups first appear. With no groupBy
+//  columns, a single row aggregates all input. The Reader must have a
+//  header. Numeric fields that cannot be parsed are reported as a
+//  *LineError. Only Count may be used without a column (ErrorNoAggColumn).
+func Aggregate(w *Writer, r *Reader, groupBy []string, aggs ...Agg) error {
+	return aggregate(w, r, groupBy, aggs, false)
+}
+
+//  Like Aggregate, but the input must be sorted by the groupBy columns in
+//  lexical order (as Sort orders SortString keys). Each group is written as
+//  soon as it ends, so only one group is held in memory. A row whose key
+//  sorts before that of the previous row is reported as a *LineError
+//  holding ErrorUnsorted.
+func AggregateSorted(w *Writer, r *Reader, groupBy []string, aggs ...Agg) error {
+	return aggregate(w, r, groupBy, aggs, true)
+}
+
+func aggregate(w *Writer, r *Reader, groupBy []string, aggs []Agg, sorted bool) error {
+	var header, err = r.Header()
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return ErrorNoHeader
+	}
+	var keys, cols []int
+	if keys, err = header.Indices(groupBy...); err != nil {
+		return err
+	}
+	cols = make([]int, len(aggs))
+	for i, a := range aggs {
+		cols[i] = -1
+		if a.Column == "" && a.Func != Count {
+			return ErrorNoAggColumn
+		}
+		if a.Column != "" {
+			var idx []int
+			if idx, err = header.Indices(a.Column); err != nil {
+				return err
+			}
+			cols[i] = idx[0]
+		}
+	}
+	var names = append([]string(nil), groupBy...)
+	for _, a := range aggs {
+		names = append(names, a.OutputName())
+	}
+	if _, err = w.WriteRow(names...); err != nil {
+		return err
+	}
+
+	var (
+		groups  = make(map[string]*aggGroup)
+		order   []*aggGroup
+		current *aggGroup
+		keyVals = make([]string, len(keys))
+	)
+	r.Do(func(row Row) bool {
+		if err = row.Error; err != nil {
+			return false
+		}
+		for i, k := range keys {
+			if k >= len(row.Fields) {
+				err = &LineError{Line: row.Line, Column: groupBy[i], Err: ErrorIndex}
+				return false
+			}
+			keyVals[i] = row.Fields[k]
+		}
+		var g *aggGroup
+		if sorted {
+			if current != nil && !equalStrings(current.key, keyVals) {
+				if lessStrings(keyVals, current.key) {
+					err = &LineError{Line: row.Line, Err: ErrorUnsorted}
+					return false
+				}
+				if err = current.write(w, aggs); err != nil {
+					return false
+				}
+				current = nil
+			}
+			if current == nil {
+				current = newAggGroup(keyVals, aggs, w.Null)
+			}
+			g = current
+		} else {
+			var hkey = strings.Join(keyVals, "\x00")
+			if g = groups[hkey]; g == nil {
+				g = newAggGroup(keyVals, aggs, w.Null)
+				groups[hkey] = g
+				order = append(order, g)
+			}
+		}
+		err = g.add(r.Config, row, header, aggs, cols)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if sorted {
+		order = nil
+		if current != nil {
+			order = append(order, current)
+		}
+	}
+	if len(keys) == 0 && len(order) == 0 {
+		order = append(order, newAggGroup(nil, aggs, w.Null))
+	}
+	for _, g := range order {
+		if err = g.write(w, aggs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//  Whether a sorts before b, comparing their elements in lexical order.
+func lessStrings(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type aggGroup struct {
+	key    []string
+	states []aggState
+	null   string
+}
+
+type aggState struct {
+	count       int
+	sum         float64
+	min, max    float64
+	first, last string
+	seen        bool // Any field was seen.
+	distinct    map[string]bool
+}
+
+func newAggGroup(key []string, aggs []Agg, null string) *aggGroup {
+	var g = &aggGroup{append([]string(nil), key...), make([]aggState, len(aggs)), null}
+	for i, a := range aggs {
+		if a.Func == CountDistinct {
+			g.states[i].distinct = make(map[string]bool)
+		}
+	}
+	return g
+}
+
+func (g *aggGroup) add(c *Config, row Row, header Header, aggs []Agg, cols []int) error {
+	for i, a := range aggs {
+		var (
+			s     = &g.states[i]
+			col   = cols[i]
+			field string
+		)
+		if col < 0 {
+			s.count++
+			continue
+		}
+		if col < len(row.Fields) {
+			field = row.Fields[col]
+		} else {
+			field = c.Null
+		}
+		switch a.Func {
+		case First:
+			if !s.seen {
+				s.first = field
+			}
+			s.seen = true
+			continue
+		case Last:
+			s.last, s.seen = field, true
+			continue
+		}
+		if c.IsNull(field) {
+			continue
+		}
+		switch a.Func {
+		case Count:
+			s.count++
+		case CountDistinct:
+			s.distinct[field] = true
+		case Sum, Min, Max, Mean:
+			var x, err = strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return &LineError{Line: row.Line, Column: header[col], Err: err}
+			}
+			if !s.seen || x < s.min {
+				s.min = x
+			}
+			if !s.seen || x > s.max {
+				s.max = x
+			}
+			s.sum += x
+			s.count++
+			s.seen = true
+		}
+	}
+	return nil
+}
+
+func (g *aggGroup) write(w *Writer, aggs []Agg) error {
+	var _, err = w.WriteRow(g.fields(aggs)...)
+	return err
+}
+
+//  The key of the group followed by its aggregates.
+func (g *aggGroup) fields(aggs []Agg) []string {
+	var (
+		row    = append([]string(nil), g.key...)
+		format = func(x float64) string {
+			return strconv.FormatFloat(x, FloatFmt, FloatPrec, 64)
+		}
+	)
+	for i, a := range aggs {
+		var s = &g.states[i]
+		var field = g.null
+		switch a.Func {
+		case Count:
+			field = strconv.Itoa(s.count)
+		case CountDistinct:
+			field = strconv.Itoa(len(s.distinct))
+		case Sum:
+			field = format(s.sum)
+		case Min:
+			if s.seen {
+				field = format(s.min)
+			}
+		case Max:
+			if s.seen {
+				field = format(s.max)
+			}
+		case Mean:
+			if s.seen {
+				field = format(s.sum / float64(s.count))
+			}
+		case First:
+			if s.seen {
+				field = s.first
+			}
+		case Last:
+			if s.seen {
+				field = s.last
+			}
+		}
+		row = append(row, field)
+	}
+	return row
+}
@@ -0,0 +1,96 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+var testAggData = `region,rep,amount
+east,alice,10
+east,bob,
+west,chris,2.5
+east,alice,5
+west,dana,4
+`
+
+var testAggs = []Agg{
+	{Func: Count},
+	{Func: Count, Column: "amount"},
+	{Func: Sum, Column: "amount", Name: "total"},
+	{Func: Min, Column: "amount"},
+	{Func: Max, Column: "amount"},
+	{Func: Mean, Column: "amount"},
+	{Func: CountDistinct, Column: "rep"},
+	{Func: First, Column: "rep"},
+	{Func: Last, Column: "rep"},
+}
+
+func TestAggregate(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader(testAggData, config)
+		w, b = BufferWriter(config)
+	)
+	if err := Aggregate(w, r, []string{"region"}, testAggs...); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	var expect = "region,count,count_amount,total,min_amount,max_amount,mean_amount,count_distinct_rep,first_rep,last_rep\n" +
+		"east,3,2,15,5,10,7.5,2,alice,alice\n" +
+		"west,2,2,6.5,2.5,4,3.25,2,chris,dana\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+}
+
+func TestAggregateSorted(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader("k,v\na,1\na,2\nb,3\nc,4\n", config)
+		w, b = BufferWriter(config)
+	)
+	if err := AggregateSorted(w, r, []string{"k"}, Agg{Func: Sum, Column: "v"}); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	if out, expect := b.String(), "k,sum_v\na,3\nb,3\nc,4\n"; out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+
+	for _, input := range []string{"k,v\na,1\na,2\nc,3\nb,4\n", "k,v\na,1\nb,2\nc,3\nb,4\n"} {
+		r = StringReader(input, config)
+		w, _ = BufferWriter(config)
+		var err = AggregateSorted(w, r, []string{"k"}, Agg{Func: Sum, Column: "v"})
+		if lerr, ok := err.(*LineError); !ok || lerr.Line != 5 || lerr.Err != ErrorUnsorted {
+			T.Errorf("Unexpected error %v for %q", err, input)
+		}
+	}
+}
+
+func TestAggregateError(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader("k,v\na,1\na,x\n", config)
+		w, _ = BufferWriter(config)
+		err  = Aggregate(w, r, nil, Agg{Func: Mean, Column: "v"})
+	)
+	if lerr, ok := err.(*LineError); !ok || lerr.Line != 3 || lerr.Column != "v" {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestAggregateNoColumn(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var w, _ = BufferWriter(config)
+	var err = Aggregate(w, StringReader("k,v\na,1\n", config), nil, Agg{Func: Sum})
+	if err != ErrorNoAggColumn {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
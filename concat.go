@@ -0,0 +1,103 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: concat.go
+*  Description: Concatenating CSV inputs with different headers.
+ */
+import (
+	"errors"
+	"strconv"
+)
+
+//  Options controlling Concat.
+type ConcatOptions struct {
+	//  When not empty, a column of this name is appended to the output
+	//  holding the source of each row.
+	SourceColumn string
+	//  The source of each input, such as its file name. Defaults to the
+	//  number of the input, starting at 1.
+	Sources []string
+}
+
+//  Returned by Concat when an input already has the source column.
+var ErrorSourceColumn = errors.New("Source column already exists.")
+
+//  Write the rows of each input, in turn, with w. Every input must have a
+//  header. The output header is the union of the input headers, in the
+//  order columns first appear, and the fields of each row are moved to
+//  their column in the output. Columns missing from an input are filled
+//  with w's Null. Only the headers of the inputs are read before writing,
+//  and rows are written as they are read. The Writer is flushed.
+func Concat(w *Writer, opts *ConcatOptions, inputs ...*Reader) error {
+	if opts == nil {
+		opts = new(ConcatOptions)
+	}
+	var (
+		union   Header
+		headers = make([]Header, len(inputs))
+		err     error
+	)
+	for i, r := range inputs {
+		if headers[i], err = r.Header(); err != nil {
+			return err
+		}
+		if headers[i] == nil {
+			return ErrorNoHeader
+		}
+		for _, name := range headers[i] {
+			if union.Index(name) < 0 {
+				union = append(union, name)
+			}
+		}
+	}
+	var width = len(union)
+	if opts.SourceColumn != "" {
+		if union.Index(opts.SourceColumn) >= 0 {
+			return ErrorSourceColumn
+		}
+		union = append(union, opts.SourceColumn)
+	}
+	if _, err = w.WriteRow(union...); err != nil {
+		return err
+	}
+	var (
+		row     = make([]string, len(union))
+		columns []int
+	)
+	for i, r := range inputs {
+		// The output column of each input column.
+		columns = columns[:0]
+		for _, name := range headers[i] {
+			columns = append(columns, union.Index(name))
+		}
+		if opts.SourceColumn != "" {
+			row[width] = strconv.Itoa(i + 1)
+			if i < len(opts.Sources) {
+				row[width] = opts.Sources[i]
+			}
+		}
+		r.Do(func(in Row) bool {
+			if err = in.Error; err != nil {
+				return false
+			}
+			for j := 0; j < width; j++ {
+				row[j] = w.Null
+			}
+			for j, k := range columns {
+				if j < len(in.Fields) {
+					row[k] = in.Fields[j]
+				}
+			}
+			_, err = w.WriteRow(row...)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,45 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+func TestConcat(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NULL"
+	var inputs = func() []*Reader {
+		return []*Reader{
+			StringReader("id,name\n1,a\n2,b\n", config),
+			StringReader("name,id,email\nc,3,c@x\n", config),
+			StringReader("email\nd@x\n", config),
+		}
+	}
+	var w, b = BufferWriter(config)
+	if err := Concat(w, nil, inputs()...); err != nil {
+		T.Fatal(err)
+	}
+	var expect = "id,name,email\n1,a,NULL\n2,b,NULL\n3,c,c@x\nNULL,NULL,d@x\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+
+	w, b = BufferWriter(config)
+	var opts = &ConcatOptions{SourceColumn: "file", Sources: []string{"jan.csv", "feb.csv"}}
+	if err := Concat(w, opts, inputs()...); err != nil {
+		T.Fatal(err)
+	}
+	expect = "id,name,email,file\n1,a,NULL,jan.csv\n2,b,NULL,jan.csv\n3,c,c@x,feb.csv\nNULL,NULL,d@x,3\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+
+	opts.SourceColumn = "email"
+	if err := Concat(w, opts, inputs()...); err != ErrorSourceColumn {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
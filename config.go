@@ -7,7 +7,10 @@ package csvutil
  *  Created:     Tue Jul 12 01:56:03 PDT 2011
  *  Description: Define the configuration type for Readers and Writers.
  */
-import ()
+import (
+	"strings"
+	"time"
+)
 
 //  A configuration structure that can be shared between a Reader and Writer.
 type Config struct {
@@ -20,12 +23,26 @@ type Config struct {
 	Cutset string
 	//  Prefix for comment lines.
 	CommentPrefix string
+	//  Field value representing NULL (missing) data.
+	Null string
+	//  Layout used to format and parse times (see package time).
+	TimeLayout string
+	//  Match decoded columns to struct fields after normalizing their names
+	//  (see NormalizeColumnName).
+	NormalizeHeader bool
 
 	// Reader specific config
 	//  Are comments allowed in the input.
 	Comments bool
 	//  Comments can appear in the body (Comments must be true).
 	CommentsInBody bool
+	//  The first row of input is a header of column names.
+	HasHeader bool
+
+	//  Values decoded for NULL, empty and missing fields, by column name
+	//  (see SetDefault). Never modified once set, so copies of a Config
+	//  can't change each other's defaults, and Configs remain comparable.
+	defaults *map[string]string
 }
 
 //  The default configuration is used for Readers and Writers when none is
@@ -33,7 +50,8 @@ type Config struct {
 var (
 	DefaultConfig = &Config{
 		Sep: ',', Trim: false, Cutset: " \t", CommentPrefix: "#",
-		Comments: false, CommentsInBody: false}
+		Null: "", TimeLayout: time.RFC3339,
+		Comments: false, CommentsInBody: false, HasHeader: false}
 )
 
 //  Return a freshly allocated Config that is initialized to DefaultConfig.
@@ -43,10 +61,38 @@ func NewConfig() *Config {
 	return c
 }
 
+//  Set the value decoded for NULL, empty and missing fields of a column.
+//  Defaults set in a Config take precedence over defaults in struct tags
+//  (see Row.Decode).
+func (c *Config) SetDefault(column, value string) {
+	var m = make(map[string]string)
+	if c.defaults != nil {
+		for k, v := range *c.defaults {
+			m[k] = v
+		}
+	}
+	m[column] = value
+	c.defaults = &m
+}
+
+//  Returns the default value of a column set with SetDefault, if any.
+func (c *Config) Default(column string) (string, bool) {
+	if c.defaults == nil {
+		return "", false
+	}
+	var value, ok = (*c.defaults)[column]
+	return value, ok
+}
+
 func (c *Config) LooksLikeComment(line string) bool {
-	return line[:len(c.CommentPrefix)] == c.CommentPrefix
+	return strings.HasPrefix(line, c.CommentPrefix)
 }
 
 func (c *Config) IsSep(rune rune) bool {
 	return rune == c.Sep
 }
+
+//  Returns true if field represents a NULL value.
+func (c *Config) IsNull(field string) bool {
+	return field == c.Null
+}
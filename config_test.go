@@ -24,6 +24,9 @@ func TestConfig(T *testing.T) {
 	if config.LooksLikeComment("/ This, is not, a comment\n") {
 		T.Error("Incorrectly labeled something a // comment")
 	}
+	if config.LooksLikeComment("/") || config.LooksLikeComment("") {
+		T.Error("Incorrectly labeled a short line a // comment")
+	}
 
 	// Test seperator detection.
 	config.Sep = '\t'
@@ -39,3 +42,26 @@ func TestConfig(T *testing.T) {
 		T.Error("Incorrectly labelled 52 a \\t separator")
 	}
 }
+
+func TestConfigDefaults(T *testing.T) {
+	var a, b = NewConfig(), NewConfig()
+	if *a != *b {
+		T.Error("Fresh configs are not equal")
+	}
+	a.SetDefault("qty", "1")
+	var c = *a
+	c.SetDefault("qty", "2")
+	c.SetDefault("unit", "box")
+	if v, ok := a.Default("qty"); !ok || v != "1" {
+		T.Errorf("Unexpected default %q, %v", v, ok)
+	}
+	if _, ok := a.Default("unit"); ok {
+		T.Error("Default set in a copy of the config")
+	}
+	if v, ok := c.Default("qty"); !ok || v != "2" {
+		T.Errorf("Unexpected default %q, %v", v, ok)
+	}
+	if _, ok := b.Default("qty"); ok {
+		T.Error("Unexpected default in a fresh config")
+	}
+}
@@ -0,0 +1,484 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: csvw.go
+*  Description: W3C CSV on the Web (CSVW) metadata.
+ */
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//  The JSON-LD context of CSVW metadata documents.
+var CSVWContext = "http://www.w3.org/ns/csvw"
+
+//  A CSVW metadata document describing a table, or a group of tables.
+//  Properties whose values may take several forms in CSVW (such as null,
+//  titles and datatype) are held as decoded JSON values.
+type CSVW struct {
+	Context     interface{}  `json:"@context,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Dialect     *CSVWDialect `json:"dialect,omitempty"`
+	TableSchema *CSVWSchema  `json:"tableSchema,omitempty"`
+	Null        interface{}  `json:"null,omitempty"`
+	Tables      []*CSVW      `json:"tables,omitempty"`
+}
+
+//  The dialect of a CSVW table.
+type CSVWDialect struct {
+	Delimiter        string      `json:"delimiter,omitempty"`
+	QuoteChar        *string     `json:"quoteChar,omitempty"`
+	Header           *bool       `json:"header,omitempty"`
+	HeaderRowCount   *int        `json:"headerRowCount,omitempty"`
+	CommentPrefix    *string     `json:"commentPrefix,omitempty"`
+	SkipInitialSpace *bool       `json:"skipInitialSpace,omitempty"`
+	SkipRows         int         `json:"skipRows,omitempty"`
+	SkipColumns      int         `json:"skipColumns,omitempty"`
+	Trim             interface{} `json:"trim,omitempty"`
+	Encoding         string      `json:"encoding,omitempty"`
+}
+
+//  The schema of a CSVW table.
+type CSVWSchema struct {
+	Columns    []CSVWColumn `json:"columns"`
+	PrimaryKey interface{}  `json:"primaryKey,omitempty"` // A string or []string.
+}
+
+//  A column of a CSVW table schema.
+type CSVWColumn struct {
+	Name     string      `json:"name,omitempty"`
+	Titles   interface{} `json:"titles,omitempty"`
+	Datatype interface{} `json:"datatype,omitempty"` // A name or an object.
+	Required bool        `json:"required,omitempty"`
+	Null     interface{} `json:"null,omitempty"`
+	Virtual  bool        `json:"virtual,omitempty"`
+}
+
+//  A CSVW datatype object. The format of a string datatype is a regular
+//  expression, and that of a date or time is a Unicode date pattern.
+type CSVWDatatype struct {
+	Base      string   `json:"base,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+}
+
+//  Read a CSVW metadata document.
+func ReadCSVW(r io.Reader) (*CSVW, error) {
+	var m = new(CSVW)
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//  Write the metadata document as indented JSON.
+func (m *CSVW) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, m)
+}
+
+//  Returns the table of a group with the given URL (or the document itself
+//  if it describes that table), or nil. The dialect and null of a group
+//  are inherited by tables without their own.
+func (m *CSVW) Table(url string) *CSVW {
+	if m.URL == url && m.Tables == nil {
+		return m
+	}
+	for _, t := range m.Tables {
+		if t.URL != url {
+			continue
+		}
+		var table = *t
+		if table.Dialect == nil {
+			table.Dialect = m.Dialect
+		}
+		if table.Null == nil {
+			table.Null = m.Null
+		}
+		if table.TableSchema == nil {
+			table.TableSchema = m.TableSchema
+		}
+		return &table
+	}
+	return nil
+}
+
+//  A Config for reading the table, set by its dialect and null values. As
+//  in CSVW, headers are expected, fields are trimmed and lines starting
+//  with "#" are comments unless the dialect says otherwise. Dialect properties that a Reader can
+//  not honor (quoting, skipped rows and columns, several header rows and
+//  encodings other than UTF-8) are reported as an *UnsupportedError. Only
+//  one null value other than the empty string may be used by the table and
+//  all its columns.
+func (m *CSVW) Config() (*Config, error) {
+	var c = NewConfig()
+	c.HasHeader = true
+	c.Trim = true
+	c.Comments = true
+	c.CommentsInBody = true
+	if d := m.Dialect; d != nil {
+		if err := d.configure(c); err != nil {
+			return nil, err
+		}
+	}
+	var nulls = jsonStrings(m.Null)
+	if m.TableSchema != nil {
+		for _, col := range m.TableSchema.Columns {
+			nulls = append(nulls, jsonStrings(col.Null)...)
+		}
+	}
+	var err error
+	if c.Null, err = singleNull("null", nulls); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (d *CSVWDialect) configure(c *Config) error {
+	if d.Delimiter != "" {
+		var sep, n = utf8.DecodeRuneInString(d.Delimiter)
+		if n != len(d.Delimiter) {
+			return &UnsupportedError{"delimiter", d.Delimiter}
+		}
+		c.Sep = sep
+	}
+	if d.QuoteChar != nil && *d.QuoteChar != `"` {
+		return &UnsupportedError{"quoteChar", *d.QuoteChar}
+	}
+	if d.SkipRows != 0 {
+		return &UnsupportedError{"skipRows", fmt.Sprint(d.SkipRows)}
+	}
+	if d.SkipColumns != 0 {
+		return &UnsupportedError{"skipColumns", fmt.Sprint(d.SkipColumns)}
+	}
+	if e := strings.ToLower(d.Encoding); e != "" && e != "utf-8" && e != "utf8" {
+		return &UnsupportedError{"encoding", d.Encoding}
+	}
+	if d.Header != nil {
+		c.HasHeader = *d.Header
+	}
+	if n := d.HeaderRowCount; n != nil {
+		if *n > 1 {
+			return &UnsupportedError{"headerRowCount", fmt.Sprint(*n)}
+		}
+		c.HasHeader = *n == 1
+	}
+	if d.CommentPrefix != nil {
+		c.CommentPrefix = *d.CommentPrefix
+		c.Comments = c.CommentPrefix != ""
+	}
+	switch d.Trim {
+	case nil:
+		// The trim property overrides skipInitialSpace when given.
+		if d.SkipInitialSpace != nil {
+			c.Trim = *d.SkipInitialSpace
+		}
+	case false:
+		c.Trim = false
+	case true, "true", "start", "end":
+		c.Trim = true
+	default:
+		return &UnsupportedError{"trim", fmt.Sprint(d.Trim)}
+	}
+	return nil
+}
+
+//  The strings of a JSON string, array of strings, or object whose values
+//  are either.
+func jsonStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var ss []string
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	case map[string]interface{}: // Titles by language.
+		var (
+			langs []string
+			ss    []string
+		)
+		for lang := range v {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			ss = append(ss, jsonStrings(v[lang])...)
+		}
+		return ss
+	}
+	return nil
+}
+
+//  Convert the table schema to a Schema. Columns are named by their first
+//  title, which is what the header of the table holds, or else by their
+//  name (and so are the columns of the primary key). Virtual columns are
+//  skipped. Integer, decimal, boolean, date and time datatypes (and those
+//  derived from them) are mapped to FieldTypes, other datatypes are
+//  strings. A datatype's minimum, maximum and maxLength become constraints,
+//  as does the format of a string datatype (a pattern). Date and time
+//  formats are converted to time layouts.
+func (m *CSVW) Schema() (*Schema, error) {
+	var c, err = m.Config()
+	if err != nil {
+		return nil, err
+	}
+	var s = &Schema{HasHeader: c.HasHeader, Null: c.Null}
+	if m.TableSchema == nil {
+		return s, nil
+	}
+	if s.PrimaryKey, err = primaryKeyNames(m.TableSchema.PrimaryKey); err != nil {
+		return nil, err
+	}
+	for i, col := range m.TableSchema.Columns {
+		if col.Virtual {
+			continue
+		}
+		var sc = SchemaColumn{Name: col.Name, Required: col.Required}
+		if titles := jsonStrings(col.Titles); len(titles) > 0 {
+			sc.Name = titles[0]
+		} else if sc.Name == "" {
+			sc.Name = fmt.Sprint("_col.", i+1) // The CSVW default.
+		}
+		var dt CSVWDatatype
+		switch v := col.Datatype.(type) {
+		case string:
+			dt.Base = v
+		case map[string]interface{}:
+			var data, _ = json.Marshal(v)
+			if err = json.Unmarshal(data, &dt); err != nil {
+				return nil, err
+			}
+		}
+		if sc.Type, sc.Layout, err = csvwType(dt.Base, dt.Format); err != nil {
+			return nil, err
+		}
+		if sc.Type == TypeString && dt.Format != "" {
+			sc.Pattern = dt.Format
+		}
+		sc.Minimum, sc.Maximum, sc.MaxLength = dt.Minimum, dt.Maximum, dt.MaxLength
+		for k, key := range s.PrimaryKey {
+			if key == col.Name {
+				s.PrimaryKey[k] = sc.Name
+			}
+		}
+		s.Columns = append(s.Columns, sc)
+	}
+	return s, nil
+}
+
+//  The FieldType and time layout of a CSVW datatype.
+func csvwType(base, format string) (FieldType, string, error) {
+	var layout string
+	switch base {
+	case "integer", "int", "long", "short", "byte",
+		"nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger",
+		"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte", "gYear":
+		return TypeInteger, "", nil
+	case "number", "decimal", "double", "float":
+		return TypeNumber, "", nil
+	case "boolean":
+		if format != "" {
+			return 0, "", &UnsupportedError{"format", format}
+		}
+		return TypeBoolean, "", nil
+	case "date":
+		layout = "2006-01-02"
+	case "time":
+		layout = "15:04:05"
+	case "datetime", "dateTime", "dateTimeStamp":
+		layout = time.RFC3339
+	default:
+		return TypeString, "", nil
+	}
+	if format == "" {
+		return TypeDatetime, layout, nil
+	}
+	var err error
+	if layout, err = datePatternLayout(format); err != nil {
+		return 0, "", err
+	}
+	return TypeDatetime, layout, nil
+}
+
+//  Pairs of Unicode date pattern fields and time layout elements, longer
+//  fields first. Fractional seconds (S, SS, ...) are converted separately.
+var datePatternFields = []string{
+	"yyyy", "2006", "MMMM", "January", "XXX", "Z07:00", "xxx", "-07:00",
+	"MMM", "Jan", "EEE", "Mon",
+	"yy", "06", "MM", "01", "dd", "02", "HH", "15", "hh", "03", "mm", "04", "ss", "05",
+	"XX", "Z0700", "xx", "-0700",
+	"M", "1", "d", "2", "h", "3", "a", "PM", "X", "Z07", "x", "-07",
+}
+
+//  Convert a Unicode date pattern to a time layout. Quoted text is copied,
+//  as is an unquoted T between a date and a time. A run of S is taken as
+//  the maximum number of digits of fractional seconds.
+func datePatternLayout(pattern string) (string, error) {
+	var layout []string
+	for i := 0; i < len(pattern); {
+		if pattern[i] == '\'' {
+			var j = strings.IndexByte(pattern[i+1:], '\'')
+			if j < 0 {
+				return "", &UnsupportedError{"format", pattern}
+			}
+			layout = append(layout, pattern[i+1:i+1+j])
+			i += j + 2
+			continue
+		}
+		if pattern[i] == 'S' {
+			var n = len(pattern[i:]) - len(strings.TrimLeft(pattern[i:], "S"))
+			if len(layout) == 0 || layout[len(layout)-1] != "." && layout[len(layout)-1] != "," {
+				return "", &UnsupportedError{"format", pattern}
+			}
+			layout = append(layout, strings.Repeat("9", n))
+			i += n
+			continue
+		}
+		var matched bool
+		for k := 0; k < len(datePatternFields); k += 2 {
+			if strings.HasPrefix(pattern[i:], datePatternFields[k]) {
+				layout = append(layout, datePatternFields[k+1])
+				i += len(datePatternFields[k])
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		var c = pattern[i]
+		if c != 'T' && ('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return "", &UnsupportedError{"format", pattern}
+		}
+		layout = append(layout, pattern[i:i+1])
+		i++
+	}
+	return strings.Join(layout, ""), nil
+}
+
+//  Convert a time layout to a Unicode date pattern, or return false if it
+//  has elements without a pattern field.
+func layoutDatePattern(layout string) (string, bool) {
+	var pattern []string
+	for i := 0; i < len(layout); {
+		// Match the longest element.
+		var best = -1
+		for k := 1; k < len(datePatternFields); k += 2 {
+			var elem = datePatternFields[k]
+			if strings.HasPrefix(layout[i:], elem) && (best < 0 || len(elem) > len(datePatternFields[best])) {
+				best = k
+			}
+		}
+		if best >= 0 {
+			pattern = append(pattern, datePatternFields[best-1])
+			i += len(datePatternFields[best])
+			continue
+		}
+		var c = layout[i]
+		if (c == '0' || c == '9') && i > 0 && (layout[i-1] == '.' || layout[i-1] == ',') {
+			var n = len(layout[i:]) - len(strings.TrimLeft(layout[i:], layout[i:i+1]))
+			pattern = append(pattern, strings.Repeat("S", n))
+			i += n
+			continue
+		}
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+			pattern = append(pattern, "'"+layout[i:i+1]+"'")
+		} else if '0' <= c && c <= '9' || c == '_' {
+			return "", false
+		} else {
+			pattern = append(pattern, layout[i:i+1])
+		}
+		i++
+	}
+	return strings.Join(pattern, ""), true
+}
+
+//  Describe CSV data written with a Config and header, whose columns are
+//  all strings.
+func NewCSVW(url string, c *Config, header Header) *CSVW {
+	var s = &Schema{HasHeader: header != nil, Null: c.Null}
+	for _, name := range header {
+		s.Columns = append(s.Columns, SchemaColumn{Name: name})
+	}
+	return NewSchemaCSVW(url, c, s)
+}
+
+//  Describe CSV data written with a Config, whose columns are described by
+//  a Schema. Allowed values have no CSVW equivalent and are not described,
+//  and datetimes whose layouts have no date pattern are strings.
+func NewSchemaCSVW(url string, c *Config, s *Schema) *CSVW {
+	var (
+		header = s.HasHeader
+		d      = &CSVWDialect{Delimiter: string(c.Sep), Header: &header}
+		prefix = ""
+	)
+	if c.Comments {
+		prefix = c.CommentPrefix
+	}
+	if prefix != "#" {
+		d.CommentPrefix = &prefix
+	}
+	if !c.Trim {
+		d.Trim = false
+	}
+	var m = &CSVW{Context: CSVWContext, URL: url, Dialect: d, TableSchema: new(CSVWSchema)}
+	if s.Null != "" {
+		m.Null = s.Null
+	}
+	if len(s.PrimaryKey) > 0 {
+		m.TableSchema.PrimaryKey = s.PrimaryKey
+	}
+	for _, col := range s.Columns {
+		var dt = CSVWDatatype{
+			Base:      "string",
+			Minimum:   col.Minimum,
+			Maximum:   col.Maximum,
+			MaxLength: col.MaxLength}
+		switch col.Type {
+		case TypeInteger:
+			dt.Base = "integer"
+		case TypeNumber:
+			dt.Base = "number"
+		case TypeBoolean:
+			dt.Base = "boolean"
+		case TypeDatetime:
+			switch col.Layout {
+			case "2006-01-02":
+				dt.Base = "date"
+			case "15:04:05":
+				dt.Base = "time"
+			case "", time.RFC3339:
+				dt.Base = "dateTime"
+			default:
+				var pattern, ok = layoutDatePattern(col.Layout)
+				if ok {
+					dt.Base, dt.Format = "dateTime", pattern
+				}
+			}
+		default:
+			dt.Format = col.Pattern
+		}
+		var cc = CSVWColumn{Name: col.Name, Titles: col.Name, Required: col.Required}
+		cc.Datatype = dt
+		if dt.Format == "" && dt.Minimum == nil && dt.Maximum == nil && dt.MaxLength == 0 {
+			cc.Datatype = dt.Base
+		}
+		m.TableSchema.Columns = append(m.TableSchema.Columns, cc)
+	}
+	return m
+}
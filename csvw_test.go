@@ -0,0 +1,191 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCSVW = `{
+  "@context": "http://www.w3.org/ns/csvw",
+  "dialect": {"delimiter": "\t", "trim": true},
+  "null": "-",
+  "tables": [{
+    "url": "stations.csv",
+    "tableSchema": {
+      "columns": [
+        {"name": "id", "titles": "Station", "datatype": "integer", "required": true},
+        {"name": "opened", "titles": {"en": "Opened"}, "datatype": {"base": "date", "format": "dd/MM/yyyy"}},
+        {"name": "code", "titles": "Code", "datatype": {"base": "string", "format": "[A-Z]+", "maxLength": 4}},
+        {"name": "depth", "titles": "Depth", "datatype": {"base": "double", "minimum": 0}, "null": "-"},
+        {"name": "uri", "virtual": true, "datatype": "anyURI"}
+      ],
+      "primaryKey": "id"
+    }
+  }]
+}`
+
+func TestCSVW(T *testing.T) {
+	var m, err = ReadCSVW(strings.NewReader(testCSVW))
+	if err != nil {
+		T.Fatal(err)
+	}
+	var table = m.Table("stations.csv")
+	if table == nil {
+		T.Fatal("Table not found")
+	}
+	var config *Config
+	if config, err = table.Config(); err != nil {
+		T.Fatal(err)
+	}
+	if config.Sep != '\t' || !config.HasHeader || !config.Trim || config.Null != "-" ||
+		!config.Comments || config.CommentPrefix != "#" {
+		T.Errorf("Unexpected config %+v", config)
+	}
+	var s *Schema
+	if s, err = table.Schema(); err != nil {
+		T.Fatal(err)
+	}
+	var zero float64
+	var expect = []SchemaColumn{
+		{Name: "Station", Type: TypeInteger, Required: true},
+		{Name: "Opened", Type: TypeDatetime, Layout: "02/01/2006"},
+		{Name: "Code", Type: TypeString, Pattern: "[A-Z]+", MaxLength: 4},
+		{Name: "Depth", Type: TypeNumber, Minimum: &zero},
+	}
+	if !reflect.DeepEqual(s.Columns, expect) || !equalStrings(s.PrimaryKey, []string{"Station"}) {
+		T.Errorf("Unexpected schema %+v", s)
+	}
+
+	var v, _ = NewValidator(s)
+	v.CollectAll = true
+	var input = "# stations\nStation\tOpened\tCode\tDepth\n1\t02/03/1999\tAB\t-\n2\t1999-03-02\tab\t-1\n"
+	var violations, _ = v.Validate(StringReader(input, config)).(ValidationError)
+	if len(violations) != 3 {
+		T.Errorf("Unexpected violations %v", violations)
+	}
+
+	// Export and read back the metadata.
+	s.Columns[1].Layout = "2006-01-02T15:04"
+	var buf bytes.Buffer
+	if err = NewSchemaCSVW("out.csv", config, s).WriteJSON(&buf); err != nil {
+		T.Fatal(err)
+	}
+	if m, err = ReadCSVW(&buf); err != nil {
+		T.Fatal(err)
+	}
+	var s2 *Schema
+	if s2, err = m.Schema(); err != nil {
+		T.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, s2) {
+		T.Errorf("Unexpected schema %+v (!= %+v)", s2, s)
+	}
+	var config2, _ = m.Config()
+	if *config2 != *config {
+		T.Errorf("Unexpected config %+v (!= %+v)", config2, config)
+	}
+
+	m = NewCSVW("plain.csv", NewConfig(), Header{"a", "b"})
+	if m.TableSchema.Columns[1].Datatype != "string" || m.Dialect.CommentPrefix == nil {
+		T.Errorf("Unexpected metadata %+v", m)
+	}
+}
+
+func TestCSVWTrim(T *testing.T) {
+	for _, test := range []struct {
+		desc string
+		trim bool
+	}{
+		{`{}`, true},
+		{`{"dialect": {"delimiter": ";"}}`, true},
+		{`{"dialect": {"trim": false}}`, false},
+		{`{"dialect": {"trim": "start"}}`, true},
+		{`{"dialect": {"skipInitialSpace": false}}`, false},
+		{`{"dialect": {"skipInitialSpace": false, "trim": true}}`, true},
+	} {
+		var m, err = ReadCSVW(strings.NewReader(test.desc))
+		if err != nil {
+			T.Fatal(err)
+		}
+		var config *Config
+		if config, err = m.Config(); err != nil || config.Trim != test.trim {
+			T.Errorf("%s: unexpected config %+v, %v", test.desc, config, err)
+		}
+	}
+
+	// Metadata written for untrimmed data says so.
+	var config = NewConfig()
+	var buf bytes.Buffer
+	if err := NewCSVW("plain.csv", config, Header{"a"}).WriteJSON(&buf); err != nil {
+		T.Fatal(err)
+	}
+	var m, err = ReadCSVW(&buf)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if config, err = m.Config(); err != nil || config.Trim {
+		T.Errorf("Unexpected config %+v, %v", config, err)
+	}
+}
+
+func TestCSVWUnsupported(T *testing.T) {
+	for _, desc := range []string{
+		`{"dialect": {"quoteChar": "'"}}`,
+		`{"dialect": {"skipRows": 2}}`,
+		`{"dialect": {"headerRowCount": 2}}`,
+		`{"null": ["NA", "-"]}`,
+	} {
+		var m, err = ReadCSVW(strings.NewReader(desc))
+		if err != nil {
+			T.Fatal(err)
+		}
+		if _, err = m.Config(); err == nil {
+			T.Errorf("No error for %s", desc)
+		}
+	}
+}
+
+func TestCSVWDatePatterns(T *testing.T) {
+	var expect = time.Date(2011, 1, 2, 15, 4, 5, 0, time.FixedZone("", -8*3600))
+	for _, test := range []struct{ format, field string }{
+		{"yyyy-MM-ddTHH:mm:ss", "2011-01-02T15:04:05"},
+		{"yyyy-MM-ddTHH:mm:ss.S", "2011-01-02T15:04:05.0"},
+		{"yyyy-MM-ddTHH:mm:ss.SSS", "2011-01-02T15:04:05.00"},
+		{"yyyy-MM-dd HH:mm:ss", "2011-01-02 15:04:05"},
+		{"yyyy-MM-ddTHH:mm:ssX", "2011-01-02T15:04:05-08"},
+		{"yyyy-MM-ddTHH:mm:ssXX", "2011-01-02T15:04:05-0800"},
+		{"yyyy-MM-ddTHH:mm:ssXXX", "2011-01-02T15:04:05-08:00"},
+		{"yyyy-MM-ddTHH:mm:ssx", "2011-01-02T15:04:05-08"},
+		{"yyyy-MM-ddTHH:mm:ssxx", "2011-01-02T15:04:05-0800"},
+		{"yyyy-MM-ddTHH:mm:ssxxx", "2011-01-02T15:04:05-08:00"},
+		{"yyyy-MM-ddTHH:mm:ss.SS X", "2011-01-02T15:04:05.0 -08"},
+	} {
+		var typ, layout, err = csvwType("dateTime", test.format)
+		if err != nil || typ != TypeDatetime {
+			T.Errorf("Unexpected type %v of %q (%v)", typ, test.format, err)
+			continue
+		}
+		var t time.Time
+		if t, err = time.Parse(layout, test.field); err != nil {
+			T.Errorf("Layout %q of %q: %v", layout, test.format, err)
+		} else if t.Year() != 2011 || t.Second() != 5 ||
+			strings.ContainsAny(test.format, "Xx") && !t.Equal(expect) {
+			T.Errorf("Unexpected time %v parsed with %q", t, test.format)
+		}
+		if pattern, ok := layoutDatePattern(layout); !ok {
+			T.Errorf("No date pattern for layout %q of %q", layout, test.format)
+		} else if layout2, _ := datePatternLayout(pattern); layout2 != layout {
+			T.Errorf("Layout %q of %q does not round-trip (%q)", layout2, pattern, layout)
+		}
+	}
+	if _, _, err := csvwType("dateTime", "yyyy-MM-dd HH:mm:S"); err == nil {
+		T.Error("No error for fractional seconds without a decimal point")
+	}
+}
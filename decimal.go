@@ -0,0 +1,141 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: decimal.go
+*  Description: Fixed-point decimal numbers and math/big fields.
+ */
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//  The precision (in bits) given to a *big.Float without one before a
+//  field is parsed into it. Binary floats can't hold most decimal fractions
+//  exactly; use Decimal (or *big.Rat) for amounts that must round-trip.
+var BigFloatPrec uint = 128
+
+var ErrorDecimal = errors.New("Invalid decimal number.")
+
+//  A fixed-point decimal number, an integer scaled by a power of ten, which
+//  keeps every digit of the field it was parsed from. Parsing "1.50" and
+//  formatting the result gives "1.50". The zero value is 0.
+//
+//  Decimal fields are parsed and formatted by Format, FormatRow, Decode and
+//  Encode through its UnmarshalText and MarshalText methods.
+type Decimal struct {
+	unscaled *big.Int // Never modified once set; nil is 0.
+	scale    int      // Number of digits after the decimal point.
+}
+
+//  Returns the Decimal unscaled*10^-scale. A negative scale is taken as 0.
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	return Decimal{new(big.Int).Set(unscaled), scale}
+}
+
+//  The largest exponent, and number of digits after the decimal point, that
+//  ParseDecimal accepts. A field like "1e999999999" would otherwise take
+//  gigabytes to hold.
+const maxDecimalExp = 10000
+
+//  Parse a decimal number like "-12.50" or "1.25e-3". An exponent that
+//  leaves no digits after the decimal point gives a scale of 0. Exponents
+//  and scales beyond 10000 (in magnitude) are an ErrorDecimal.
+func ParseDecimal(s string) (Decimal, error) {
+	var mantissa, exp = s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.Atoi(s[i+1:]); err != nil {
+			return Decimal{}, ErrorDecimal
+		}
+		if exp > maxDecimalExp || exp < -maxDecimalExp {
+			return Decimal{}, ErrorDecimal
+		}
+		mantissa = s[:i]
+	}
+	var digits = strings.TrimLeft(mantissa, "+-")
+	if len(mantissa)-len(digits) > 1 {
+		return Decimal{}, ErrorDecimal
+	}
+	var scale int
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return Decimal{}, ErrorDecimal
+	}
+	var unscaled, _ = new(big.Int).SetString(digits, 10)
+	if mantissa[0] == '-' {
+		unscaled.Neg(unscaled)
+	}
+	if scale -= exp; scale > maxDecimalExp || scale < -maxDecimalExp {
+		return Decimal{}, ErrorDecimal
+	} else if scale < 0 {
+		unscaled.Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	return Decimal{unscaled, scale}, nil
+}
+
+//  The unscaled integer value of d.
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+//  The number of digits after the decimal point of d.
+func (d Decimal) Scale() int { return d.scale }
+
+//  The exact value of d as a fraction.
+func (d Decimal) Rat() *big.Rat {
+	var denom = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled(), denom)
+}
+
+//  Compare the values of d and e (ignoring their scales), returning -1, 0 or
+//  +1 like big.Rat's Cmp.
+func (d Decimal) Cmp(e Decimal) int { return d.Rat().Cmp(e.Rat()) }
+
+//  Format d with all the digits of its scale.
+func (d Decimal) String() string {
+	var (
+		unscaled = d.Unscaled()
+		sign     = ""
+	)
+	if unscaled.Sign() < 0 {
+		sign = "-"
+		unscaled.Neg(unscaled)
+	}
+	var digits = unscaled.String()
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	var point = len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+//  Implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+//  Implements encoding.TextUnmarshaler, with ParseDecimal.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	var x, err = ParseDecimal(string(text))
+	if err == nil {
+		*d = x
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseDecimal(T *testing.T) {
+	var tests = []struct {
+		s, expect string
+		scale     int
+	}{
+		{"12345678901234567890.01", "12345678901234567890.01", 2},
+		{"-1.50", "-1.50", 2},
+		{"+0.001", "0.001", 3},
+		{"-.5", "-0.5", 1},
+		{"42", "42", 0},
+		{"1.25e-3", "0.00125", 5},
+		{"1.5E3", "1500", 0},
+		{"1.234e1", "12.34", 2},
+	}
+	for _, test := range tests {
+		var d, err = ParseDecimal(test.s)
+		if err != nil || d.String() != test.expect || d.Scale() != test.scale {
+			T.Errorf("%q: unexpected decimal %v (scale %d), %v", test.s, d, d.Scale(), err)
+		}
+	}
+	for _, s := range []string{"1e10000", "1e-10000", "0.5e-9999"} {
+		if _, err := ParseDecimal(s); err != nil {
+			T.Errorf("%q: unexpected error %v", s, err)
+		}
+	}
+	for _, s := range []string{"", ".", "-", "1.2.3", "--1", "1e", "1x", "1e2.5",
+		"1e10001", "1e-10001", "1e999999999", "1e-999999999", "0.5e-10000", "10.5e10002"} {
+		if _, err := ParseDecimal(s); err != ErrorDecimal {
+			T.Errorf("%q: unexpected error %v", s, err)
+		}
+	}
+	var a, _ = ParseDecimal("1.50")
+	var b = NewDecimal(big.NewInt(15), 1)
+	if a.Cmp(b) != 0 || a.Rat().Cmp(big.NewRat(3, 2)) != 0 || (Decimal{}).String() != "0" {
+		T.Errorf("Unexpected comparison of %v and %v", a, b)
+	}
+}
+
+func TestBigFields(T *testing.T) {
+	type amount struct {
+		Int   *big.Int   `csv:"int"`
+		Float *big.Float `csv:"float"`
+		Rat   *big.Rat   `csv:"rat"`
+		Dec   Decimal    `csv:"dec" validate:"min=0"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	var input = "int,float,rat,dec\n" +
+		"123456789012345678901234567890,0.5,1/3,12345678901234567890.01\n"
+	var r = StringReader(input, config)
+	var a amount
+	if err := r.Decode(&a); err != nil {
+		T.Fatal(err)
+	}
+	if a.Int.String() != "123456789012345678901234567890" || a.Float.Prec() != BigFloatPrec ||
+		a.Rat.Cmp(big.NewRat(1, 3)) != 0 || a.Dec.String() != "12345678901234567890.01" {
+		T.Errorf("Unexpected amount %+v", a)
+	}
+	var w, buf = BufferWriter(config)
+	var header, _ = r.Header()
+	w.WriteRow(header...)
+	w.Encode(header, &a)
+	w.Flush()
+	if out := buf.String(); out != input {
+		T.Errorf("Unexpected output %q", out)
+	}
+	if row := FormatRow(a.Int, a.Dec, &a.Dec); !equalStrings(row.Fields,
+		[]string{"123456789012345678901234567890", "12345678901234567890.01", "12345678901234567890.01"}) {
+		T.Errorf("Unexpected row %v", row)
+	}
+
+	var (
+		n   *big.Int
+		d   Decimal
+		row = Row{Fields: []string{"-7", "0.10"}}
+	)
+	if k, err := row.Format(&n, &d); err != nil || k != 2 || n.Int64() != -7 || d.String() != "0.10" {
+		T.Errorf("Unexpected format %d %v %v, %v", k, n, d, err)
+	}
+	r = StringReader("int,dec\n1,-0.01\n", config)
+	if lerr, ok := r.Decode(new(amount)).(*LineError); !ok || lerr.Column != "dec" || lerr.Err != ErrorRange {
+		T.Errorf("Unexpected error %v", lerr)
+	}
+	if _, err := (Row{Fields: []string{"1.x"}}).Format(&d); err != ErrorDecimal {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestTextTypesOnly(T *testing.T) {
+	type event struct {
+		When time.Time  `csv:"when"`
+		Then *time.Time `csv:"then"`
+		Type FieldType  `csv:"type"`
+	}
+	var (
+		config = NewConfig()
+		when   = time.Date(2011, 1, 2, 15, 4, 5, 0, time.UTC)
+	)
+	config.TimeLayout = "2006-01-02"
+	var w, buf = BufferWriter(config)
+	if _, err := w.Encode(nil, event{when, &when, TypeNumber}); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	if out, expect := buf.String(), "2011-01-02,2011-01-02,"+strconv.Itoa(int(TypeNumber))+"\n"; out != expect {
+		T.Errorf("Unexpected output %q (!= %q)", out, expect)
+	}
+
+	// Times are not parsed with UnmarshalText, which ignores TimeLayout.
+	config.HasHeader = true
+	var e event
+	if err := StringReader("when\n2011-01-02T15:04:05Z\n", config).Decode(&e); err == nil {
+		T.Errorf("Decoded %v ignoring TimeLayout", e.When)
+	}
+}
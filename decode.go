@@ -0,0 +1,599 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: decode.go
+*  Description: Decoding rows into structs by column name.
+ */
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//  Returned by decoding when a string field has the wrong length.
+var ErrorLength = errors.New("Value has the wrong length.")
+
+//  An invalid `csv` or `validate` struct tag.
+type TagError struct {
+	Field string // Name of the struct field.
+	Tag   string
+	Msg   string
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("Invalid tag %q of field %s: %s.", e.Tag, e.Field, e.Msg)
+}
+
+//  Decode the row into the struct pointed to by v, or into a map with string
+//  keys (or a pointer to one). Maps get every column of the row's Header:
+//  maps of strings get fields as they are, maps of interface{} values get
+//  fields as InferValue returns them, and other maps get fields parsed like
+//  those of Format (omitting NULL fields).
+//
+//  Columns of the row's Header are matched to exported struct fields by the
+//  name in their `csv` tag, or else by the field's name. Fields tagged
+//  `csv:"-"` are skipped, as are columns without a field. Fields are parsed
+//  like those of Format.
+//
+//  A field of a map type with string keys tagged `csv:",extra"` holds the
+//  columns that do not match any other field, decoded like those of a map.
+//
+//  A complex number tagged with the parts option takes two columns, its
+//  real and imaginary parts, named like `csv:"z,parts"` (columns z_re and
+//  z_im) or `csv:"z,parts=I|Q"` (columns I and Q). Otherwise, complex
+//  numbers take one column in the notation of strconv.ParseComplex.
+//
+//  Other names of a column can be given by alias options, separated by '|'
+//  (or in several options), like `csv:"customer_id,alias=Customer ID|CUSTID"`.
+//  When a Reader's Config has NormalizeHeader set, names are compared after
+//  NormalizeColumnName. A field matching several columns, or a column
+//  matching several fields, is an *AmbiguousColumnError.
+//
+//  NULL (or empty) and missing fields are replaced by the column's default,
+//  if it has one, and otherwise leave struct fields unchanged. Defaults are
+//  given by a Config's SetDefault, or by a default option in a `csv` tag
+//  (which can not contain commas), and are decoded and validated like
+//  other fields. For example, `csv:"qty,default=1"`.
+//
+//  Struct fields can also have a `validate` tag of comma separated rules,
+//  which are checked as the row is decoded:
+//
+//      required    The field is not NULL, empty or missing.
+//      min=N       Numbers are at least N, strings have at least N characters.
+//      max=N       Numbers are at most N, strings have at most N characters.
+//      len=N       The field has exactly N characters.
+//      oneof=A B   The field is one of the space separated values.
+//      regex=RE    The field matches the regular expression entirely. This
+//                  rule must come last, as RE may contain commas.
+//
+//  For example,
+//
+//      type Person struct {
+//          Name string `csv:"name" validate:"required,max=40"`
+//          Age  int    `csv:"age" validate:"required,min=0,max=150"`
+//          Sex  string `csv:"sex" validate:"oneof=f m x"`
+//      }
+//
+//  Errors parsing and validating fields are returned as a *LineError naming
+//  the column. Invalid tags are returned as a *TagError.
+func (r Row) Decode(v interface{}) error {
+	return decodeRow(DefaultConfig, r, v, nil)
+}
+
+//  Read a row and decode it into the struct pointed to by v (see
+//  Row.Decode), treating the Config's Null as NULL and using its defaults
+//  and NormalizeHeader. The Reader must have a header. Returns io.EOF at
+//  the end of input.
+func (csvr *Reader) Decode(v interface{}) error {
+	var r = csvr.ReadRow()
+	if r.Error != nil {
+		return r.Error
+	}
+	if r.Header == nil {
+		return ErrorNoHeader
+	}
+	if csvr.decoding == nil {
+		csvr.decoding = new(decodeCache)
+	}
+	return decodeRow(csvr.Config, r, v, csvr.decoding)
+}
+
+//  The columns matched to the fields of the last struct type decoded.
+type decodeCache struct {
+	t    reflect.Type
+	cols []int
+}
+
+func decodeRow(c *Config, r Row, v interface{}, cache *decodeCache) error {
+	if r.Header == nil {
+		return ErrorNoHeader
+	}
+	var ptr = reflect.ValueOf(v)
+	if ptr.Kind() == reflect.Map && !ptr.IsNil() {
+		return decodeMap(c, r, ptr, nil)
+	}
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return ErrorNonPointer
+	}
+	var s = ptr.Elem()
+	if s.Kind() == reflect.Map {
+		return decodeMap(c, r, s, nil)
+	}
+	if s.Kind() != reflect.Struct {
+		return ErrorFieldType
+	}
+	var info, err = structInfoOf(s.Type())
+	if err != nil {
+		return err
+	}
+	var cols []int
+	if cache != nil && cache.t == s.Type() {
+		cols = cache.cols
+	} else {
+		if cols, err = info.match(r.Header, c.NormalizeHeader); err != nil {
+			return err
+		}
+		if cache != nil {
+			cache.t, cache.cols = s.Type(), cols
+		}
+	}
+	for i := range info.fields {
+		var (
+			f      = &info.fields[i]
+			col    = cols[i]
+			column = f.name
+			field  string
+		)
+		if col >= 0 {
+			column = r.Header[col]
+			field = fieldOr(r.Fields, col, "")
+		}
+		if field == "" || c.IsNull(field) {
+			var def, ok = c.Default(f.name)
+			if !ok {
+				def, ok = f.def, f.hasDef
+			}
+			if !ok {
+				if f.rules.required {
+					return &LineError{Line: r.Line, Column: column, Err: ErrorRequired}
+				}
+				continue
+			}
+			field = def
+		}
+		var fv = s.FieldByIndex(f.index)
+		var xv = fv // Decoded value, a float for a part of a complex number.
+		if f.part != 0 {
+			xv = reflect.New(complexPart(fv, f.part).Type()).Elem()
+		}
+		if _, err = (Row{Fields: []string{field}}).formatReflectValue(0, xv); err != nil {
+			return &LineError{Line: r.Line, Column: column, Err: err}
+		}
+		if err = f.rules.check(field, xv); err != nil {
+			return &LineError{Line: r.Line, Column: column, Err: err}
+		}
+		if f.part != 0 {
+			setComplexPart(fv, f.part, xv.Float())
+		}
+	}
+	if info.extra == nil {
+		return nil
+	}
+	var used = make([]bool, len(r.Header))
+	for _, col := range cols {
+		if col >= 0 {
+			used[col] = true
+		}
+	}
+	return decodeMap(c, r, s.FieldByIndex(info.extra), used)
+}
+
+//  Decode the columns of a row, except those used, into a map with string
+//  keys. Fields are stored as strings in maps of strings, are inferred (see
+//  InferValue) in maps of interface{} values, and are otherwise parsed like
+//  those of Format. NULL fields are omitted from maps of other types.
+func decodeMap(c *Config, r Row, m reflect.Value, used []bool) error {
+	var (
+		t  = m.Type()
+		et = t.Elem()
+	)
+	if t.Key().Kind() != reflect.String || et.Kind() == reflect.Interface && et.NumMethod() > 0 {
+		return ErrorFieldType
+	}
+	if m.IsNil() {
+		if !m.CanSet() {
+			return ErrorCantSet
+		}
+		m.Set(reflect.MakeMap(t))
+	}
+	for j, column := range r.Header {
+		if used != nil && used[j] {
+			continue
+		}
+		var (
+			field = fieldOr(r.Fields, j, "")
+			null  = field == "" || c.IsNull(field)
+			ev    reflect.Value
+		)
+		switch et.Kind() {
+		case reflect.String:
+			ev = reflect.ValueOf(field).Convert(et)
+		case reflect.Interface:
+			ev = reflect.Zero(et)
+			if !null {
+				ev = reflect.ValueOf(InferValue(c, field))
+			}
+		default:
+			if null {
+				continue
+			}
+			ev = reflect.New(et).Elem()
+			if _, err := (Row{Fields: []string{field}}).formatReflectValue(0, ev); err != nil {
+				return &LineError{Line: r.Line, Column: column, Err: err}
+			}
+		}
+		m.SetMapIndex(reflect.ValueOf(column).Convert(t.Key()), ev)
+	}
+	return nil
+}
+
+//  Returns the field as the first of an int64, float64, bool or time.Time
+//  (in the Config's TimeLayout) that it can be parsed as, or else as a
+//  string. Fields that the Config considers NULL, and empty fields, are
+//  nil.
+func InferValue(c *Config, field string) interface{} {
+	if field == "" || c.IsNull(field) {
+		return nil
+	}
+	var trimmed = strings.TrimSpace(field)
+	if x, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+		return x
+	}
+	if x, err := strconv.ParseFloat(trimmed, 64); err == nil {
+		return x
+	}
+	if x, err := strconv.ParseBool(trimmed); err == nil {
+		return x
+	}
+	if x, err := time.Parse(c.TimeLayout, trimmed); err == nil {
+		return x
+	}
+	return field
+}
+
+//  Returned when a struct field matches several columns of a header, or a
+//  column matches several struct fields.
+type AmbiguousColumnError struct {
+	Fields  []string // Names of the struct fields.
+	Columns []string
+}
+
+func (e *AmbiguousColumnError) Error() string {
+	return fmt.Sprintf("Ambiguous match of fields %v to columns %q.", e.Fields, e.Columns)
+}
+
+//  Normalize a column name for matching: a leading byte order mark is
+//  removed, case is folded, and spaces, underscores and hyphens are
+//  removed. So "Customer ID", "customer_id" and "CUSTOMERID" are equal.
+func NormalizeColumnName(name string) string {
+	name = strings.TrimPrefix(name, "\uFEFF")
+	return strings.Map(func(c rune) rune {
+		if unicode.IsSpace(c) || c == '_' || c == '-' {
+			return -1
+		}
+		return unicode.ToLower(c)
+	}, name)
+}
+
+//  The index in the header of the column of each field, or -1.
+func (info *structInfo) match(header Header, normalize bool) ([]int, error) {
+	var (
+		cols  = make([]int, len(info.fields))
+		owner = make(map[int]int) // Field of each matched column.
+	)
+	for i := range info.fields {
+		var f = &info.fields[i]
+		cols[i] = -1
+		for j, column := range header {
+			if !f.matches(column, normalize) {
+				continue
+			}
+			if cols[i] >= 0 {
+				return nil, &AmbiguousColumnError{
+					Fields: []string{f.field}, Columns: []string{header[cols[i]], column}}
+			}
+			if k, ok := owner[j]; ok {
+				return nil, &AmbiguousColumnError{
+					Fields: []string{info.fields[k].field, f.field}, Columns: []string{column}}
+			}
+			cols[i] = j
+			owner[j] = i
+		}
+	}
+	return cols, nil
+}
+
+//  Whether a column name matches the name or an alias of the field.
+func (f *structField) matches(column string, normalize bool) bool {
+	if normalize {
+		column = NormalizeColumnName(column)
+	}
+	for _, name := range append([]string{f.name}, f.aliases...) {
+		if normalize {
+			name = NormalizeColumnName(name)
+		}
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+//  The decoded fields of a struct type.
+type structInfo struct {
+	fields []structField
+	extra  []int // Index of the map holding other columns, or nil.
+}
+
+type structField struct {
+	index   []int
+	field   string // Name of the struct field.
+	name    string // Column name.
+	aliases []string
+	def     string // Default value, if hasDef.
+	hasDef  bool
+	part    int // For complex numbers in two columns, partReal or partImag.
+	rules   fieldRules
+}
+
+//  The parts of a complex number in two columns.
+const (
+	partReal = 1 + iota
+	partImag
+)
+
+//  The real or imaginary part of a complex number, as a float of half its
+//  size.
+func complexPart(x reflect.Value, part int) reflect.Value {
+	var c = x.Complex()
+	var f = real(c)
+	if part == partImag {
+		f = imag(c)
+	}
+	if x.Kind() == reflect.Complex64 {
+		return reflect.ValueOf(float32(f))
+	}
+	return reflect.ValueOf(f)
+}
+
+//  Set the real or imaginary part of a complex number.
+func setComplexPart(x reflect.Value, part int, f float64) {
+	var c = x.Complex()
+	if part == partReal {
+		x.SetComplex(complex(f, imag(c)))
+	} else {
+		x.SetComplex(complex(real(c), f))
+	}
+}
+
+//  The rules of a `validate` tag.
+type fieldRules struct {
+	required bool
+	min, max *float64
+	length   int // Required length, or -1.
+	oneof    []string
+	pattern  *regexp.Regexp
+}
+
+var (
+	structInfoMu    sync.RWMutex
+	structInfoCache = make(map[reflect.Type]*structInfo)
+)
+
+//  The (cached) fields of a struct type.
+func structInfoOf(t reflect.Type) (*structInfo, error) {
+	structInfoMu.RLock()
+	var info = structInfoCache[t]
+	structInfoMu.RUnlock()
+	if info != nil {
+		return info, nil
+	}
+	info = new(structInfo)
+	for i := 0; i < t.NumField(); i++ {
+		var sf = t.Field(i)
+		if sf.PkgPath != "" { // Unexported.
+			continue
+		}
+		var tag = sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		var f = structField{index: sf.Index, field: sf.Name, name: sf.Name}
+		var opts = strings.Split(tag, ",")
+		if opts[0] != "" {
+			f.name = opts[0]
+		}
+		if len(opts) == 2 && opts[1] == "extra" {
+			if sf.Type.Kind() != reflect.Map || sf.Type.Key().Kind() != reflect.String || info.extra != nil {
+				return nil, &TagError{sf.Name, tag, "extra columns need one map with string keys"}
+			}
+			info.extra = sf.Index
+			continue
+		}
+		var parts []string
+		for _, opt := range opts[1:] {
+			switch {
+			case opt == "parts":
+				parts = []string{f.name + "_re", f.name + "_im"}
+			case strings.HasPrefix(opt, "parts="):
+				if parts = strings.Split(opt[len("parts="):], "|"); len(parts) != 2 {
+					return nil, &TagError{sf.Name, tag, "parts needs two column names"}
+				}
+			case strings.HasPrefix(opt, "default="):
+				f.def, f.hasDef = opt[len("default="):], true
+			case strings.HasPrefix(opt, "alias="):
+				f.aliases = append(f.aliases, strings.Split(opt[len("alias="):], "|")...)
+			default:
+				return nil, &TagError{sf.Name, tag, "unknown option " + opt}
+			}
+		}
+		var err error
+		if f.rules, err = parseRules(sf.Name, sf.Tag.Get("validate")); err != nil {
+			return nil, err
+		}
+		if parts == nil {
+			info.fields = append(info.fields, f)
+			continue
+		}
+		if !complexParts(sf) || f.aliases != nil {
+			return nil, &TagError{sf.Name, tag, "parts needs a complex number without aliases"}
+		}
+		var re, im = f, f
+		re.name, re.part = parts[0], partReal
+		im.name, im.part = parts[1], partImag
+		info.fields = append(info.fields, re, im)
+	}
+	structInfoMu.Lock()
+	structInfoCache[t] = info
+	structInfoMu.Unlock()
+	return info, nil
+}
+
+//  Parse a `validate` tag.
+func parseRules(field, tag string) (fieldRules, error) {
+	var rules = fieldRules{length: -1}
+	for rest := tag; rest != ""; {
+		var rule string
+		if strings.HasPrefix(rest, "regex=") {
+			rule, rest = rest, ""
+		} else if i := strings.Index(rest, ","); i >= 0 {
+			rule, rest = rest[:i], rest[i+1:]
+		} else {
+			rule, rest = rest, ""
+		}
+		var name, arg = rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		var err error
+		switch name {
+		case "required":
+			rules.required = true
+		case "min", "max":
+			var x float64
+			if x, err = strconv.ParseFloat(arg, 64); err == nil {
+				if name == "min" {
+					rules.min = &x
+				} else {
+					rules.max = &x
+				}
+			}
+		case "len":
+			rules.length, err = strconv.Atoi(arg)
+		case "oneof":
+			rules.oneof = strings.Fields(arg)
+		case "regex":
+			rules.pattern, err = regexp.Compile("^(?:" + arg + ")$")
+		default:
+			return rules, &TagError{field, tag, "unknown rule " + name}
+		}
+		if err != nil {
+			return rules, &TagError{field, tag, err.Error()}
+		}
+	}
+	return rules, nil
+}
+
+//  Check a non-NULL field, decoded into v, against the rules.
+func (rules *fieldRules) check(field string, v reflect.Value) error {
+	var (
+		n      = utf8.RuneCountInString(field)
+		x      float64
+		number = true
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		x = v.Float()
+	default:
+		x, number = bigFloat64(v)
+	}
+	if number {
+		if rules.min != nil && x < *rules.min || rules.max != nil && x > *rules.max {
+			return ErrorRange
+		}
+	} else if rules.min != nil && float64(n) < *rules.min || rules.max != nil && float64(n) > *rules.max {
+		return ErrorLength
+	}
+	if rules.length >= 0 && n != rules.length {
+		return ErrorLength
+	}
+	if rules.oneof != nil && !containsString(rules.oneof, field) {
+		return ErrorNotAllowed
+	}
+	if rules.pattern != nil && !rules.pattern.MatchString(field) {
+		return ErrorPattern
+	}
+	return nil
+}
+
+//  The value of a *big.Int, *big.Float, *big.Rat or Decimal (or a value one
+//  points to) as a float64, for checking against min and max rules.
+func bigFloat64(v reflect.Value) (float64, bool) {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return 0, false
+	}
+	var f float64
+	switch x := v.Interface().(type) {
+	case *big.Int:
+		f, _ = new(big.Float).SetInt(x).Float64()
+	case *big.Float:
+		f, _ = x.Float64()
+	case *big.Rat:
+		f, _ = x.Float64()
+	case *Decimal:
+		f, _ = x.Rat().Float64()
+	default:
+		return 0, false
+	}
+	return f, true
+}
+
+//  Reads the remaining rows of input, decoding each into a new element of
+//  the slice pointed to by v, whose elements are structs.
+func (csvr *Reader) DecodeAll(v interface{}) error {
+	var ptr = reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return ErrorNonPointer
+	}
+	var slice = ptr.Elem()
+	for {
+		var elem = reflect.New(slice.Type().Elem())
+		var err = csvr.Decode(elem.Interface())
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testPerson struct {
+	Name   string  `csv:"name" validate:"required,max=10"`
+	Age    int     `csv:"age" validate:"required,min=0,max=150"`
+	Sex    string  `csv:"sex" validate:"oneof=f m x"`
+	State  string  `csv:"state" validate:"len=2,regex=[A-Z]{2}"`
+	Score  float64 // Matched by name.
+	Secret string  `csv:"-"`
+	hidden string
+}
+
+func TestDecode(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NA"
+	var r = StringReader("age,name,sex,state,Score,Secret,extra\n"+
+		"34,alice,f,CA,1.5,x,y\n"+
+		"17,bob,NA,NY,,x,y\n", config)
+	var people []testPerson
+	if err := r.DecodeAll(&people); err != nil {
+		T.Fatal(err)
+	}
+	var expect = []testPerson{
+		{Name: "alice", Age: 34, Sex: "f", State: "CA", Score: 1.5},
+		{Name: "bob", Age: 17, State: "NY"},
+	}
+	if len(people) != len(expect) {
+		T.Fatalf("Unexpected people %v", people)
+	}
+	for i := range people {
+		if people[i] != expect[i] {
+			T.Errorf("Unexpected person %+v (!= %+v)", people[i], expect[i])
+		}
+	}
+	var p testPerson
+	if err := r.Decode(&p); err != io.EOF {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestDecodeValidate(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var tests = []struct {
+		row    string
+		column string
+		err    error
+	}{
+		{"alice,34,f,CA", "", nil},
+		{",34,f,CA", "name", ErrorRequired},
+		{"alice,,f,CA", "age", ErrorRequired},
+		{"alice,151,f,CA", "age", ErrorRange},
+		{"alice,-1,f,CA", "age", ErrorRange},
+		{"alice-in-wonderland,1,f,CA", "name", ErrorLength},
+		{"alice,34,q,CA", "sex", ErrorNotAllowed},
+		{"alice,34,f,CAL", "state", ErrorLength},
+		{"alice,34,f,ca", "state", ErrorPattern},
+	}
+	for _, test := range tests {
+		var (
+			r   = StringReader("name,age,sex,state\n"+test.row+"\n", config)
+			p   testPerson
+			err = r.Decode(&p)
+		)
+		if test.err == nil {
+			if err != nil {
+				T.Errorf("%q: unexpected error %v", test.row, err)
+			}
+			continue
+		}
+		var lerr, ok = err.(*LineError)
+		if !ok || lerr.Line != 2 || lerr.Column != test.column || lerr.Err != test.err {
+			T.Errorf("%q: unexpected error %v", test.row, err)
+		}
+	}
+
+	var r = StringReader("age\nx\n", config)
+	var age struct {
+		Age int `csv:"age"`
+	}
+	if lerr, ok := r.Decode(&age).(*LineError); !ok || lerr.Column != "age" {
+		T.Errorf("Unexpected error %v", lerr)
+	}
+	var bad struct {
+		X int `validate:"between=1"`
+	}
+	r = StringReader("X\n1\n", config)
+	if _, ok := r.Decode(&bad).(*TagError); !ok {
+		T.Error("No error for an invalid tag")
+	}
+}
+
+func TestDecodeOverflow(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	type narrow struct {
+		Age   int8  `validate:"min=0,max=150"`
+		Count uint8 `validate:"max=300"`
+	}
+	var tests = []struct {
+		row    string
+		column string
+	}{
+		{"127,255", ""},
+		{"300,1", "Age"},
+		{"-129,1", "Age"},
+		{"1,256", "Count"},
+		{"1,300", "Count"},
+	}
+	for _, test := range tests {
+		var (
+			r   = StringReader("Age,Count\n"+test.row+"\n", config)
+			x   narrow
+			err = r.Decode(&x)
+		)
+		if test.column == "" {
+			if err != nil || x.Age != 127 || x.Count != 255 {
+				T.Errorf("%q: unexpected %+v (%v)", test.row, x, err)
+			}
+			continue
+		}
+		var lerr, ok = err.(*LineError)
+		if !ok || lerr.Column != test.column {
+			T.Errorf("%q: unexpected error %v", test.row, err)
+			continue
+		}
+		if nerr, ok := lerr.Err.(*strconv.NumError); !ok || nerr.Err != strconv.ErrRange {
+			T.Errorf("%q: unexpected error %v", test.row, lerr.Err)
+		}
+	}
+}
+
+func TestDecodeDefaults(T *testing.T) {
+	type item struct {
+		Name  string  `csv:"name" validate:"required"`
+		Qty   int     `csv:"qty,default=1" validate:"min=1"`
+		Price float64 `csv:"price"`
+		Unit  string  `csv:"unit,default=each"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	config.SetDefault("price", "9.5")
+	config.SetDefault("unit", "box")
+	// An older producer, without the qty and unit columns.
+	var r = StringReader("name,price\nnut,\nbolt,2\n", config)
+	var items []item
+	if err := r.DecodeAll(&items); err != nil {
+		T.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != (item{"nut", 1, 9.5, "box"}) || items[1] != (item{"bolt", 1, 2, "box"}) {
+		T.Errorf("Unexpected items %+v", items)
+	}
+
+	config = NewConfig()
+	config.HasHeader = true
+	config.SetDefault("name", "x")
+	config.SetDefault("qty", "0")
+	r = StringReader("name,qty\n,\n", config)
+	var it item
+	if lerr, ok := r.Decode(&it).(*LineError); !ok || lerr.Column != "qty" || lerr.Err != ErrorRange {
+		T.Errorf("Unexpected error %v", lerr)
+	}
+}
+
+func TestDecodeAliases(T *testing.T) {
+	type customer struct {
+		ID   int    `csv:"customer_id,alias=Customer ID|CUSTOMERID"`
+		Name string `csv:"name,alias=full name"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	for _, header := range []string{"customer_id,name", "Customer ID,name", "CUSTOMERID,full name"} {
+		var (
+			r = StringReader(header+"\n7,ann\n", config)
+			c customer
+		)
+		if err := r.Decode(&c); err != nil || c != (customer{7, "ann"}) {
+			T.Errorf("%q: unexpected result %+v, %v", header, c, err)
+		}
+	}
+
+	config.NormalizeHeader = true
+	for _, header := range []string{"\uFEFFCustomer-Id,NAME", "customer id,Full_Name"} {
+		var (
+			r = StringReader(header+"\n7,ann\n", config)
+			c customer
+		)
+		if err := r.Decode(&c); err != nil || c != (customer{7, "ann"}) {
+			T.Errorf("%q: unexpected result %+v, %v", header, c, err)
+		}
+	}
+
+	for _, header := range []string{"customer_id,CUSTOMERID", "name,fullname"} {
+		var (
+			r = StringReader(header+"\n7,8\n", config)
+			c customer
+		)
+		if _, ok := r.Decode(&c).(*AmbiguousColumnError); !ok {
+			T.Errorf("%q: no ambiguity error", header)
+		}
+	}
+	type twice struct {
+		A string `csv:"a"`
+		B string `csv:"b,alias=A"`
+	}
+	var r = StringReader("A\nx\n", config)
+	if _, ok := r.Decode(new(twice)).(*AmbiguousColumnError); !ok {
+		T.Error("No ambiguity error for a column matching two fields")
+	}
+}
+
+func TestDecodeMap(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NA"
+	var r = StringReader("id,name,score,ok,seen\n7,ann,1.5,true,2011-06-01T00:00:00Z\n8,NA,,x,\n", config)
+	var m map[string]interface{}
+	if err := r.Decode(&m); err != nil {
+		T.Fatal(err)
+	}
+	var seen = time.Date(2011, 6, 1, 0, 0, 0, 0, time.UTC)
+	if m["id"] != int64(7) || m["name"] != "ann" || m["score"] != 1.5 || m["ok"] != true || m["seen"] != seen {
+		T.Errorf("Unexpected map %v", m)
+	}
+	var s = make(map[string]string)
+	if err := r.Decode(s); err != nil {
+		T.Fatal(err)
+	}
+	if len(s) != 5 || s["id"] != "8" || s["name"] != "NA" || s["ok"] != "x" || s["seen"] != "" {
+		T.Errorf("Unexpected map %v", s)
+	}
+
+	r = StringReader("a,b,c\n1,NA,3\n", config)
+	var ints map[string]int
+	if err := r.Decode(&ints); err != nil || len(ints) != 2 || ints["a"] != 1 || ints["c"] != 3 {
+		T.Errorf("Unexpected map %v, %v", ints, err)
+	}
+	var row = Row{Fields: []string{"x", "1", "2"}, Header: Header{"name", "a", "b"}}
+	var name string
+	ints = nil
+	if n, err := row.Format(&name, &ints); err != nil || n != 3 || name != "x" || ints["a"] != 1 || ints["b"] != 2 {
+		T.Errorf("Unexpected format %d %q %v, %v", n, name, ints, err)
+	}
+	if err := row.Decode(new(map[int]string)); err != ErrorFieldType {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestDecodeExtra(T *testing.T) {
+	type record struct {
+		ID    int               `csv:"id"`
+		Extra map[string]string `csv:",extra"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	var r = StringReader("note,id,tag\nhi,7,a\n", config)
+	var rec record
+	if err := r.Decode(&rec); err != nil {
+		T.Fatal(err)
+	}
+	if rec.ID != 7 || len(rec.Extra) != 2 || rec.Extra["note"] != "hi" || rec.Extra["tag"] != "a" {
+		T.Errorf("Unexpected record %+v", rec)
+	}
+	var bad struct {
+		Extra []string `csv:",extra"`
+	}
+	if _, ok := (Row{Fields: []string{"x"}, Header: Header{"x"}}).Decode(&bad).(*TagError); !ok {
+		T.Error("No error for an extra field that is not a map")
+	}
+}
+
+func TestDecodeComplex(T *testing.T) {
+	type sample struct {
+		T  int        `csv:"t"`
+		Z  complex128 `csv:"z"`
+		IQ complex64  `csv:"iq,parts=I|Q" validate:"min=-1,max=1"`
+		W  complex128 `csv:"w,parts"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	var r = StringReader("t,Q,z,I,w_re,w_im\n1,-0.25,(2-3i),0.5,4,5\n2,2,0,0,0,0\n", config)
+	var s sample
+	if err := r.Decode(&s); err != nil {
+		T.Fatal(err)
+	}
+	if s != (sample{1, 2 - 3i, complex(0.5, -0.25), 4 + 5i}) {
+		T.Errorf("Unexpected sample %+v", s)
+	}
+	if lerr, ok := r.Decode(&s).(*LineError); !ok || lerr.Column != "Q" || lerr.Err != ErrorRange {
+		T.Errorf("Unexpected error %v", lerr)
+	}
+	var bad struct {
+		X float64 `csv:"x,parts"`
+	}
+	if _, ok := (Row{Fields: []string{"1"}, Header: Header{"x"}}).Decode(&bad).(*TagError); !ok {
+		T.Error("No error for parts of a float")
+	}
+}
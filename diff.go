@@ -0,0 +1,268 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: diff.go
+*  Description: Keyed differences between two CSV files.
+ */
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+//  The kind of a change to a row.
+type ChangeOp int
+
+const (
+	Added    ChangeOp = iota // A row with a new key.
+	Modified                 // A row whose fields changed.
+	Removed                  // A row whose key no longer exists.
+)
+
+var changeOpNames = []string{"added", "modified", "removed"}
+
+func (op ChangeOp) String() string {
+	if op < 0 || int(op) >= len(changeOpNames) {
+		return "unknown"
+	}
+	return changeOpNames[op]
+}
+
+//  Parse the name of a ChangeOp. The synonyms "insert", "update" and
+//  "delete" are also accepted. Case is ignored.
+func ParseChangeOp(s string) (ChangeOp, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "added", "add", "insert":
+		return Added, nil
+	case "modified", "modify", "update":
+		return Modified, nil
+	case "removed", "remove", "delete":
+		return Removed, nil
+	}
+	return 0, ErrorChangeOp
+}
+
+var (
+	ErrorDuplicateKey = errors.New("Duplicate key.")
+	ErrorChangeOp     = errors.New("Unknown change operation.")
+)
+
+//  The name of the operation column in patch CSV data.
+var PatchOpColumn = "op"
+
+//  A changed field of a modified row.
+type FieldChange struct {
+	Column   string
+	Old, New string
+}
+
+//  A change to one row.
+type Change struct {
+	Op     ChangeOp
+	Key    []string      // Values of the key columns.
+	Line   int           // Line of the row (in the old input if Removed).
+	Old    []string      // Old row, ordered like OldHeader (nil if Added).
+	New    []string      // New row, ordered like NewHeader (nil if Removed).
+	Fields []FieldChange // Changed fields, if Modified.
+}
+
+//  The differences between two CSV files.
+type DiffResult struct {
+	Keys      []string
+	OldHeader Header
+	NewHeader Header
+	Changes   []Change
+}
+
+//  Compare an old and a new CSV input, whose rows are identified by the
+//  given key columns. Both inputs must have headers, whose columns may be
+//  in different orders. Rows are modified when any field of a column common
+//  to both headers differs. Changes are listed in the order of the new
+//  input, followed by removed rows in the order of the old input. The old
+//  input is held in memory. Duplicate keys are reported as a *LineError
+//  with ErrorDuplicateKey.
+func Diff(from, to io.Reader, keyColumns []string, c *Config) (*DiffResult, error) {
+	if c == nil {
+		c = NewConfig()
+	}
+	var config = *c
+	config.HasHeader = true
+	var (
+		d       = &DiffResult{Keys: keyColumns}
+		oldr    = NewReader(from, &config)
+		newr    = NewReader(to, &config)
+		oldKeys []int
+		newKeys []int
+		err     error
+	)
+	if d.OldHeader, err = oldr.Header(); err != nil {
+		return nil, err
+	}
+	if d.NewHeader, err = newr.Header(); err != nil {
+		return nil, err
+	}
+	if oldKeys, err = d.OldHeader.Indices(keyColumns...); err != nil {
+		return nil, err
+	}
+	if newKeys, err = d.NewHeader.Indices(keyColumns...); err != nil {
+		return nil, err
+	}
+
+	// Index the old rows by key.
+	var (
+		index   = make(map[string]int)
+		oldRows []Row
+		seen    []bool
+	)
+	for r := oldr.ReadRow(); !r.HasEOF(); r = oldr.ReadRow() {
+		if r.HasError() {
+			return nil, r.Error
+		}
+		var key, err = rowKey(r, oldKeys, d.OldHeader)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := index[key]; ok {
+			return nil, &LineError{Line: r.Line, Err: ErrorDuplicateKey}
+		}
+		index[key] = len(oldRows)
+		oldRows = append(oldRows, r)
+	}
+	seen = make([]bool, len(oldRows))
+
+	// Columns common to both headers, by index in each.
+	var common [][2]int
+	for j, name := range d.NewHeader {
+		if i := d.OldHeader.Index(name); i >= 0 {
+			common = append(common, [2]int{i, j})
+		}
+	}
+
+	for r := newr.ReadRow(); !r.HasEOF(); r = newr.ReadRow() {
+		if r.HasError() {
+			return nil, r.Error
+		}
+		var key, err = rowKey(r, newKeys, d.NewHeader)
+		if err != nil {
+			return nil, err
+		}
+		var i, ok = index[key]
+		if !ok {
+			d.Changes = append(d.Changes, Change{
+				Op: Added, Key: pick(r.Fields, newKeys), Line: r.Line, New: r.Fields})
+			continue
+		}
+		if seen[i] {
+			return nil, &LineError{Line: r.Line, Err: ErrorDuplicateKey}
+		}
+		seen[i] = true
+		var fields []FieldChange
+		for _, ij := range common {
+			var (
+				o = fieldOr(oldRows[i].Fields, ij[0], config.Null)
+				n = fieldOr(r.Fields, ij[1], config.Null)
+			)
+			if o != n {
+				fields = append(fields, FieldChange{d.NewHeader[ij[1]], o, n})
+			}
+		}
+		if fields != nil {
+			d.Changes = append(d.Changes, Change{
+				Op: Modified, Key: pick(r.Fields, newKeys), Line: r.Line,
+				Old: oldRows[i].Fields, New: r.Fields, Fields: fields})
+		}
+	}
+	for i, r := range oldRows {
+		if !seen[i] {
+			d.Changes = append(d.Changes, Change{
+				Op: Removed, Key: pick(r.Fields, oldKeys), Line: r.Line, Old: r.Fields})
+		}
+	}
+	return d, nil
+}
+
+//  The key of a row as a single string.
+func rowKey(r Row, keys []int, header Header) (string, error) {
+	var vals = make([]string, len(keys))
+	for i, k := range keys {
+		if k >= len(r.Fields) {
+			return "", &LineError{Line: r.Line, Column: header[k], Err: ErrorIndex}
+		}
+		vals[i] = r.Fields[k]
+	}
+	return strings.Join(vals, "\x00"), nil
+}
+
+func pick(fields []string, indices []int) []string {
+	var picked = make([]string, len(indices))
+	for i, j := range indices {
+		picked[i] = fields[j]
+	}
+	return picked
+}
+
+//  Returns fields[i], or def if the row is too short.
+func fieldOr(fields []string, i int, def string) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return def
+}
+
+//  Returns the header of the patch written by WritePatch: the operation
+//  column, the key columns, and the other columns of the new header.
+func (d *DiffResult) PatchHeader() Header {
+	var header = Header{PatchOpColumn}
+	header = append(header, d.Keys...)
+	for _, name := range d.NewHeader {
+		if !containsString(d.Keys, name) {
+			header = append(header, name)
+		}
+	}
+	return header
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+//  Write the changes as patch CSV data, with a header given by
+//  PatchHeader. Added and modified rows hold their new values. Removed
+//  rows hold only their keys, and the Writer's Null in other columns.
+func (d *DiffResult) WritePatch(w *Writer) error {
+	var header = d.PatchHeader()
+	if _, err := w.WriteRow(header...); err != nil {
+		return err
+	}
+	var (
+		index = make([]int, len(header)-1-len(d.Keys))
+		row   = make([]string, len(header))
+	)
+	for i, name := range header[1+len(d.Keys):] {
+		index[i] = d.NewHeader.Index(name)
+	}
+	for _, ch := range d.Changes {
+		row[0] = ch.Op.String()
+		copy(row[1:], ch.Key)
+		for i, j := range index {
+			if ch.New == nil {
+				row[1+len(d.Keys)+i] = w.Null
+			} else {
+				row[1+len(d.Keys)+i] = fieldOr(ch.New, j, w.Null)
+			}
+		}
+		if _, err := w.WriteRow(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
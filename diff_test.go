@@ -0,0 +1,61 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	testDiffOld = "id,name,qty\n1,apple,5\n2,pear,3\n3,fig,0\n"
+	testDiffNew = "qty,id,name,note\n5,1,apple,x\n4,2,pear,y\n7,4,kiwi,z\n"
+)
+
+func TestDiff(T *testing.T) {
+	var d, err = Diff(strings.NewReader(testDiffOld), strings.NewReader(testDiffNew),
+		[]string{"id"}, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(d.Changes) != 3 {
+		T.Fatalf("%d changes (!= 3): %v", len(d.Changes), d.Changes)
+	}
+	var mod = d.Changes[0]
+	if mod.Op != Modified || mod.Key[0] != "2" || len(mod.Fields) != 1 {
+		T.Errorf("Unexpected change %v", mod)
+	} else if f := mod.Fields[0]; f.Column != "qty" || f.Old != "3" || f.New != "4" {
+		T.Errorf("Unexpected field change %v", f)
+	}
+	if add := d.Changes[1]; add.Op != Added || add.Key[0] != "4" || add.Line != 4 {
+		T.Errorf("Unexpected change %v", add)
+	}
+	if rem := d.Changes[2]; rem.Op != Removed || rem.Key[0] != "3" || rem.Old[1] != "fig" {
+		T.Errorf("Unexpected change %v", rem)
+	}
+
+	var config = NewConfig()
+	config.Null = "NULL"
+	var w, b = BufferWriter(config)
+	if err = d.WritePatch(w); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	var expect = "op,id,qty,name,note\n" +
+		"modified,2,4,pear,y\n" +
+		"added,4,7,kiwi,z\n" +
+		"removed,3,NULL,NULL,NULL\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected patch\n%s\n(!=\n%s)", out, expect)
+	}
+}
+
+func TestDiffDuplicate(T *testing.T) {
+	var _, err = Diff(strings.NewReader("id\n1\n1\n"), strings.NewReader("id\n1\n"),
+		[]string{"id"}, nil)
+	if lerr, ok := err.(*LineError); !ok || lerr.Err != ErrorDuplicateKey || lerr.Line != 3 {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
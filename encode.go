@@ -0,0 +1,143 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: encode.go
+*  Description: Write structs and maps as rows, the inverse of Decode.
+ */
+import (
+	"reflect"
+	"sort"
+)
+
+//  Returns the columns that Encode writes for v, a struct or a map with
+//  string keys (or a pointer to one). A struct's columns are those Decode
+//  matches, in field order, followed by the sorted keys of its extra map.
+//  A map's columns are its sorted keys.
+func HeaderOf(v interface{}) (Header, error) {
+	var value = reflect.Indirect(reflect.ValueOf(v))
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, ErrorFieldType
+		}
+		return sortedMapKeys(value), nil
+	case reflect.Struct:
+		var info, err = structInfoOf(value.Type())
+		if err != nil {
+			return nil, err
+		}
+		var header = make(Header, 0, len(info.fields))
+		for _, f := range info.fields {
+			header = append(header, f.name)
+		}
+		if info.extra != nil {
+			header = append(header, sortedMapKeys(value.FieldByIndex(info.extra))...)
+		}
+		return header, nil
+	}
+	return nil, ErrorFieldType
+}
+
+//  Write v, a struct or a map with string keys (or a pointer to one), as a
+//  row with a field for each column of header. Struct fields are matched to
+//  columns as Decode matches them (using the Writer's NormalizeHeader), and
+//  columns without a field are looked up in the struct's extra map. When
+//  header is nil, the columns are those of HeaderOf(v); encoding every row
+//  of a table with one header keeps the columns of rows aligned.
+//
+//  Missing columns, nil values and nil pointers are written as the Writer's
+//  Null, times are formatted with its TimeLayout, and other values are
+//  formatted like FormatRow formats them. Returns the number of bytes
+//  written and any error encountered.
+func (csvw *Writer) Encode(header Header, v interface{}) (int, error) {
+	var (
+		value = reflect.Indirect(reflect.ValueOf(v))
+		err   error
+	)
+	if header == nil {
+		if header, err = HeaderOf(v); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		fields = make([]string, len(header))
+		values = make([]reflect.Value, len(header))
+	)
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return 0, ErrorFieldType
+		}
+		mapValues(value, header, values)
+	case reflect.Struct:
+		var info *structInfo
+		if info, err = structInfoOf(value.Type()); err != nil {
+			return 0, err
+		}
+		var cols []int
+		if cols, err = info.match(header, csvw.NormalizeHeader); err != nil {
+			return 0, err
+		}
+		for i, col := range cols {
+			if col < 0 {
+				continue
+			}
+			var f = &info.fields[i]
+			if values[col] = value.FieldByIndex(f.index); f.part != 0 {
+				values[col] = complexPart(values[col], f.part)
+			}
+		}
+		if info.extra != nil {
+			mapValues(value.FieldByIndex(info.extra), header, values)
+		}
+	default:
+		return 0, ErrorFieldType
+	}
+	for j, x := range values {
+		if fields[j], err = csvw.encodeValue(x); err != nil {
+			return 0, &LineError{Column: header[j], Err: err}
+		}
+	}
+	return csvw.WriteRow(fields...)
+}
+
+//  Fill the unset values of the header's columns from a map.
+func mapValues(m reflect.Value, header Header, values []reflect.Value) {
+	if m.IsNil() {
+		return
+	}
+	for j, column := range header {
+		if values[j].IsValid() {
+			continue
+		}
+		values[j] = m.MapIndex(reflect.ValueOf(column).Convert(m.Type().Key()))
+	}
+}
+
+//  Format a struct field or map value as a field.
+func (c *Config) encodeValue(x reflect.Value) (string, error) {
+	for x.IsValid() && (x.Kind() == reflect.Ptr || x.Kind() == reflect.Interface) {
+		if x.IsNil() {
+			return c.Null, nil
+		}
+		x = x.Elem()
+	}
+	if !x.IsValid() {
+		return c.Null, nil
+	}
+	return c.formatSQLValue(x.Interface())
+}
+
+//  The keys of a map with string keys, sorted.
+func sortedMapKeys(m reflect.Value) []string {
+	var keys = make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+func TestEncode(T *testing.T) {
+	type record struct {
+		ID    int               `csv:"id"`
+		Name  string            `csv:"name"`
+		Skip  string            `csv:"-"`
+		Extra map[string]string `csv:",extra"`
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NA"
+	var input = "note,id,name,tag\nhi,7,ann,a\n"
+	var r = StringReader(input, config)
+	var rec record
+	if err := r.Decode(&rec); err != nil {
+		T.Fatal(err)
+	}
+	var header, err = HeaderOf(&rec)
+	if err != nil || !equalStrings(header, []string{"id", "name", "note", "tag"}) {
+		T.Errorf("Unexpected header %v, %v", header, err)
+	}
+
+	// Round trip extra columns in the input's order.
+	var in, _ = r.Header()
+	var w, buf = BufferWriter(config)
+	w.WriteRow(in...)
+	if _, err = w.Encode(in, &rec); err != nil {
+		T.Fatal(err)
+	}
+	rec.Name = ""
+	delete(rec.Extra, "tag")
+	if _, err = w.Encode(in, rec); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	if out := buf.String(); out != input+"hi,7,,NA\n" {
+		T.Errorf("Unexpected output %q", out)
+	}
+
+	w, buf = BufferWriter(config)
+	var m = map[string]interface{}{"b": 2.5, "a": "x", "c": nil}
+	w.Encode(nil, m)
+	w.Encode(Header{"c", "a", "z"}, &m)
+	w.Flush()
+	if out := buf.String(); out != "x,2.5,NA\nNA,x,NA\n" {
+		T.Errorf("Unexpected output %q", out)
+	}
+	if _, err = w.Encode(nil, 1); err != ErrorFieldType {
+		T.Errorf("Unexpected error %v", err)
+	}
+	if row := FormatRow(map[string]int{"b": 2, "a": 1}); row.Error != nil || !equalStrings(row.Fields, []string{"1", "2"}) {
+		T.Errorf("Unexpected row %v", row)
+	}
+}
+
+func TestEncodeComplex(T *testing.T) {
+	type sample struct {
+		Z  complex128 `csv:"z"`
+		IQ complex64  `csv:"iq,parts=I|Q"`
+	}
+	var s = sample{2 - 3i, complex(0.1, -0.25)}
+	var header, err = HeaderOf(s)
+	if err != nil || !equalStrings(header, []string{"z", "I", "Q"}) {
+		T.Errorf("Unexpected header %v, %v", header, err)
+	}
+	var w, buf = BufferWriter(nil)
+	w.Encode(nil, s)
+	w.Encode(Header{"Q", "I"}, &s)
+	w.Flush()
+	if out := buf.String(); out != "(2-3i),0.1,-0.25\n-0.25,0.1\n" {
+		T.Errorf("Unexpected output %q", out)
+	}
+}
+
+type testStatus string
+type testLevel int8
+type testCount uint
+type testRatio float32
+type testFlag bool
+
+func TestEncodeNamedTypes(T *testing.T) {
+	type record struct {
+		Status testStatus `csv:"status"`
+		Level  testLevel  `csv:"level"`
+		Count  testCount  `csv:"count"`
+		Ratio  testRatio  `csv:"ratio"`
+		Flag   testFlag   `csv:"flag"`
+	}
+	var rec = record{"open", -3, 7, 0.5, true}
+	var w, buf = BufferWriter(nil)
+	if _, err := w.Encode(nil, &rec); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	if out := buf.String(); out != "open,-3,7,0.5,true\n" {
+		T.Errorf("Unexpected output %q", out)
+	}
+	if row := FormatRow(testStatus("closed"), testLevel(2)); row.Error != nil ||
+		!equalStrings(row.Fields, []string{"closed", "2"}) {
+		T.Errorf("Unexpected row %v", row)
+	}
+}
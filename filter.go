@@ -0,0 +1,576 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: filter.go
+*  Description: A small expression language for filtering rows.
+ */
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//  A compiled filter expression. Filters match Rows that have a Header,
+//  referring to fields by column name. For example,
+//
+//      age > 30 && country in ('US', 'CA') && name =~ '^A'
+//
+//  Comparisons (==, !=, <, <=, >, >=) between a column and a number are
+//  numeric, between a column and a 'string' are lexical, and between a
+//  column and a time('2011-07-12') are chronological. Comparisons
+//  between two columns are numeric when both fields are numbers and
+//  lexical otherwise. A field that cannot be parsed as the type of a
+//  comparison does not satisfy it. Other tests are set membership (in,
+//  not in), regular expression matching (=~, !~) and null checks (is
+//  null, is not null), where a field is null if it is empty or its
+//  column does not exist. Tests are combined with &&, || and ! (or and,
+//  or and not) and grouped with parentheses.
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+//  A syntax error in a filter expression.
+type FilterError struct {
+	Expr string // The expression.
+	Pos  int    // Position of the error (starting at 1).
+	Msg  string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %q: position %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+//  Layouts tried when parsing times in filters.
+var FilterTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+//  Compile a filter expression. Errors are of type *FilterError.
+func CompileFilter(expr string) (*Filter, error) {
+	var toks, err = lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	var p = &filterParser{expr: expr, toks: toks}
+	var root filterNode
+	if root, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+	if p.peek().kind != filterEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return &Filter{expr, root}, nil
+}
+
+//  Like CompileFilter, but panics if the expression cannot be compiled.
+func MustCompileFilter(expr string) *Filter {
+	var f, err = CompileFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+//  Returns the source expression of the filter.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+//  Returns true if the row satisfies the filter.
+func (f *Filter) Match(r Row) bool {
+	return f.root.match(r)
+}
+
+//  Wrap a function for Reader.Do so that it is only called with rows that
+//  satisfy the filter, or that have a non-EOF error.
+//
+//      csvr.Do(filter.Do(func(r csvutil.Row) bool { ... }))
+func (f *Filter) Do(g func(Row) bool) func(Row) bool {
+	return func(r Row) bool {
+		if r.HasError() || f.Match(r) {
+			return g(r)
+		}
+		return true
+	}
+}
+
+const (
+	filterEOF = iota
+	filterIdent
+	filterNum
+	filterStr
+	filterOp
+	filterTm // Time literals (only as comparison kinds).
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int // Byte offset.
+}
+
+func lexFilter(expr string) ([]filterToken, error) {
+	var (
+		toks []filterToken
+		s    = expr
+	)
+	for i := 0; i < len(s); {
+		var c, size = utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '_' || unicode.IsLetter(c):
+			var j = i
+			for j < len(s) {
+				var d, n = utf8.DecodeRuneInString(s[j:])
+				if d != '_' && !unicode.IsLetter(d) && !unicode.IsDigit(d) {
+					break
+				}
+				j += n
+			}
+			toks = append(toks, filterToken{filterIdent, s[i:j], i})
+			i = j
+		case c == '-' || c == '.' || '0' <= c && c <= '9':
+			var j = filterNumberEnd(s, i)
+			if _, ok := parseFilterNumber(s[i:j]); !ok {
+				return nil, &FilterError{expr, i + 1, fmt.Sprintf("bad number %q", s[i:j])}
+			}
+			toks = append(toks, filterToken{filterNum, s[i:j], i})
+			i = j
+		case c == '\'' || c == '"' || c == '`':
+			// Quoted strings, or column names in backquotes. Doubled
+			// quotes escape.
+			var (
+				text []byte
+				j    = i + 1
+			)
+			for ; ; j++ {
+				if j >= len(s) {
+					return nil, &FilterError{expr, i + 1, "unterminated quote"}
+				}
+				if s[j] == byte(c) {
+					if j+1 < len(s) && s[j+1] == byte(c) {
+						j++
+					} else {
+						break
+					}
+				}
+				text = append(text, s[j])
+			}
+			var kind = filterStr
+			if c == '`' {
+				kind = filterIdent
+			}
+			toks = append(toks, filterToken{kind, string(text), i})
+			i = j + 1
+		default:
+			var op string
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "=~", "!~", "&&", "||":
+					op = two
+				}
+			}
+			if op == "" {
+				switch c {
+				case '<', '>', '!', '(', ')', ',':
+					op = s[i : i+1]
+				default:
+					return nil, &FilterError{expr, i + 1, fmt.Sprintf("unexpected %q", c)}
+				}
+			}
+			toks = append(toks, filterToken{filterOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, filterToken{filterEOF, "", len(s)}), nil
+}
+
+//  The end of the number starting at s[i], in the syntax of
+//  strconv.ParseFloat: digits, letters, '.' and '_', with a sign after the
+//  exponent's e (or p, in hexadecimal).
+func filterNumberEnd(s string, i int) int {
+	var (
+		exp    = "eE"
+		digits = strings.TrimPrefix(s[i:], "-")
+	)
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		exp = "pP"
+	}
+	var j = i + 1
+	for ; j < len(s); j++ {
+		switch c := s[j]; {
+		case c == '.' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case (c == '-' || c == '+') && strings.IndexByte(exp, s[j-1]) >= 0:
+		default:
+			return j
+		}
+	}
+	return j
+}
+
+//  Parse a finite number. NaN and infinities are not numbers in filters.
+func parseFilterNumber(s string) (float64, bool) {
+	var x, err = strconv.ParseFloat(s, 64)
+	return x, err == nil && !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+type filterParser struct {
+	expr string
+	toks []filterToken
+	i    int
+}
+
+func (p *filterParser) peek() filterToken { return p.toks[p.i] }
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return &FilterError{p.expr, p.peek().pos + 1, fmt.Sprintf(format, args...)}
+}
+
+//  Consume the next token if it is the given operator or keyword.
+func (p *filterParser) accept(text string) bool {
+	var t = p.peek()
+	if t.kind == filterOp && t.text == text || t.kind == filterIdent && strings.EqualFold(t.text, text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	var left, err = p.parseAnd()
+	for err == nil && (p.accept("||") || p.accept("or")) {
+		var right filterNode
+		if right, err = p.parseAnd(); err == nil {
+			left = filterOr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	var left, err = p.parseNot()
+	for err == nil && (p.accept("&&") || p.accept("and")) {
+		var right filterNode
+		if right, err = p.parseNot(); err == nil {
+			left = filterAnd{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.accept("!") || p.accept("not") {
+		var n, err = p.parseNot()
+		return filterNot{n}, err
+	}
+	if p.accept("(") {
+		var n, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.parseTest()
+}
+
+func (p *filterParser) parseTest() (filterNode, error) {
+	var left, err = p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.accept("is"):
+		var not = p.accept("not")
+		if err = p.expect("null"); err != nil {
+			return nil, err
+		}
+		var n filterNode = filterNull{left}
+		if not {
+			n = filterNot{n}
+		}
+		return n, nil
+	case p.accept("in"):
+		return p.parseIn(left)
+	case p.accept("not"):
+		if err = p.expect("in"); err != nil {
+			return nil, err
+		}
+		var n filterNode
+		n, err = p.parseIn(left)
+		return filterNot{n}, err
+	case p.accept("=~"), p.accept("!~"):
+		var (
+			op = p.toks[p.i-1].text
+			t  = p.peek()
+		)
+		if t.kind != filterStr {
+			return nil, p.errorf("expected a regular expression string")
+		}
+		var re, err = regexp.Compile(t.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.i++
+		var n filterNode = filterRegexp{left, re}
+		if op == "!~" {
+			n = filterNot{n}
+		}
+		return n, nil
+	}
+	var op = p.peek()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if op.kind == filterOp {
+			p.i++
+			break
+		}
+		fallthrough
+	default:
+		return nil, p.errorf("expected a comparison")
+	}
+	var right filterOperand
+	if right, err = p.parseOperand(); err != nil {
+		return nil, err
+	}
+	return filterCompare{op.text, left, right}, nil
+}
+
+func (p *filterParser) parseIn(left filterOperand) (filterNode, error) {
+	var (
+		n   = filterIn{x: left}
+		err error
+	)
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		var lit filterOperand
+		if lit, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if _, ok := lit.(filterColumn); ok {
+			return nil, &FilterError{p.expr, p.toks[p.i-1].pos + 1, "expected a literal"}
+		}
+		n.set = append(n.set, lit)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return n, p.expect(")")
+}
+
+func (p *filterParser) parseOperand() (filterOperand, error) {
+	var t = p.peek()
+	switch t.kind {
+	case filterNum:
+		p.i++
+		var f, _ = strconv.ParseFloat(t.text, 64)
+		return filterNumber(f), nil
+	case filterStr:
+		p.i++
+		return filterString(t.text), nil
+	case filterIdent:
+		switch strings.ToLower(t.text) {
+		case "and", "or", "not", "in", "is", "null":
+			return nil, p.errorf("expected a column or value")
+		case "time":
+			if p.toks[p.i+1].text != "(" {
+				break
+			}
+			p.i += 2
+			var arg = p.peek()
+			if arg.kind != filterStr {
+				return nil, p.errorf("expected a time string")
+			}
+			var tm, ok = parseFilterTime(arg.text)
+			if !ok {
+				return nil, p.errorf("bad time %q", arg.text)
+			}
+			p.i++
+			return filterTime(tm), p.expect(")")
+		}
+		p.i++
+		return filterColumn(t.text), nil
+	}
+	return nil, p.errorf("expected a column or value")
+}
+
+func parseFilterTime(s string) (time.Time, bool) {
+	for _, layout := range FilterTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+type filterNode interface {
+	match(r Row) bool
+}
+
+//  Operands evaluate to a field, and false if it is null.
+type filterOperand interface {
+	field(r Row) (string, bool)
+}
+
+type filterColumn string
+type filterNumber float64
+type filterString string
+type filterTime time.Time
+
+func (c filterColumn) field(r Row) (string, bool) {
+	var s, ok = r.Get(string(c))
+	return s, ok && s != ""
+}
+func (n filterNumber) field(r Row) (string, bool) {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64), true
+}
+func (s filterString) field(r Row) (string, bool) { return string(s), true }
+func (t filterTime) field(r Row) (string, bool) {
+	return time.Time(t).Format(time.RFC3339Nano), true
+}
+
+type filterAnd struct{ left, right filterNode }
+type filterOr struct{ left, right filterNode }
+type filterNot struct{ n filterNode }
+type filterNull struct{ x filterOperand }
+
+func (n filterAnd) match(r Row) bool { return n.left.match(r) && n.right.match(r) }
+func (n filterOr) match(r Row) bool  { return n.left.match(r) || n.right.match(r) }
+func (n filterNot) match(r Row) bool { return !n.n.match(r) }
+func (n filterNull) match(r Row) bool {
+	var _, ok = n.x.field(r)
+	return !ok
+}
+
+type filterRegexp struct {
+	x  filterOperand
+	re *regexp.Regexp
+}
+
+func (n filterRegexp) match(r Row) bool {
+	var s, ok = n.x.field(r)
+	return ok && n.re.MatchString(s)
+}
+
+type filterIn struct {
+	x   filterOperand
+	set []filterOperand
+}
+
+func (n filterIn) match(r Row) bool {
+	for _, y := range n.set {
+		if cmp, ok := filterCompareOperands(r, n.x, y); ok && cmp == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type filterCompare struct {
+	op          string
+	left, right filterOperand
+}
+
+func (n filterCompare) match(r Row) bool {
+	var cmp, ok = filterCompareOperands(r, n.left, n.right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+//  Compare two operands as the type of any literal among them. The second
+//  return value is false if either operand is null or cannot be parsed.
+func filterCompareOperands(r Row, x, y filterOperand) (int, bool) {
+	var a, oka = x.field(r)
+	var b, okb = y.field(r)
+	if !oka || !okb {
+		return 0, false
+	}
+	var kind = func(op filterOperand) int {
+		switch op.(type) {
+		case filterNumber:
+			return filterNum
+		case filterTime:
+			return filterTm
+		case filterString:
+			return filterStr
+		}
+		return filterIdent
+	}
+	var k = kind(x)
+	if k == filterIdent {
+		k = kind(y)
+	}
+	switch k {
+	case filterStr:
+		return strings.Compare(a, b), true
+	case filterTm:
+		var (
+			ta, oka = parseFilterTime(a)
+			tb, okb = parseFilterTime(b)
+		)
+		if !oka || !okb {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+	var (
+		fa, numa = parseFilterNumber(a)
+		fb, numb = parseFilterNumber(b)
+	)
+	if !numa || !numb {
+		if k == filterNum {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,115 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"strings"
+	"testing"
+)
+
+var testFilterData = `name,age,country,joined
+Alice,34,US,2010-03-01
+Aaron,29,CA,2011-01-15
+bob,45,US,
+Ann,,MX,2009-12-31
+`
+
+func TestFilter(T *testing.T) {
+	var tests = []struct {
+		expr   string
+		expect string
+	}{
+		{"age > 30 && country in ('US','CA') && name =~ '^A'", "Alice"},
+		{"age >= 29 and age < 40", "Alice,Aaron"},
+		{"age is null || joined is null", "bob,Ann"},
+		{"!(country == 'US') && name !~ 'r'", "Ann"},
+		{"country not in ('US') or age > 40", "Aaron,bob,Ann"},
+		{"joined < time('2010-06-01')", "Alice,Ann"},
+		{"name > 'B'", "bob"},
+		{"height is null && `age` != 34", "Aaron,bob"},
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	for _, test := range tests {
+		var filter, err = CompileFilter(test.expr)
+		if err != nil {
+			T.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		var names []string
+		StringReader(testFilterData, config).Do(filter.Do(func(r Row) bool {
+			if r.HasError() {
+				T.Error(r.Error)
+				return false
+			}
+			names = append(names, r.Fields[0])
+			return true
+		}))
+		if out := strings.Join(names, ","); out != test.expect {
+			T.Errorf("%s: matched %q (!= %q)", test.expr, out, test.expect)
+		}
+	}
+}
+
+func TestFilterErrors(T *testing.T) {
+	var tests = []struct {
+		expr string
+		pos  int
+	}{
+		{"age >", 6},
+		{"age > 30 &&", 12},
+		{"name =~ '('", 9},
+		{"(age > 1", 9},
+		{"age ? 3", 5},
+		{"name == 'x", 9},
+		{"age in (1, name)", 12},
+		{"age > 1e", 7},
+		{"age > -Inf", 7},
+		{"age > 1.5.2", 7},
+	}
+	for _, test := range tests {
+		var _, err = CompileFilter(test.expr)
+		ferr, ok := err.(*FilterError)
+		if !ok {
+			T.Errorf("%s: unexpected error %v", test.expr, err)
+			continue
+		}
+		if ferr.Pos != test.pos {
+			T.Errorf("%s: error at %d (!= %d): %v", test.expr, ferr.Pos, test.pos, ferr)
+		}
+	}
+}
+
+func TestFilterNumbers(T *testing.T) {
+	var data = "name,score,café\na,5,x\nb,NaN,y\nc,1e-5,z\nd,+Inf,w\n"
+	var tests = []struct {
+		expr   string
+		expect string
+	}{
+		{"score == 5", "a"},
+		{"score != 5", "c"},
+		{"score > 1e-5", "a"},
+		{"score >= 1E-5 && score < 0x1p-2", "c"},
+		{"score in (5, 1e-5)", "a,c"},
+		{"café == 'z' || `café` == 'x'", "a,c"},
+	}
+	var config = NewConfig()
+	config.HasHeader = true
+	for _, test := range tests {
+		var filter, err = CompileFilter(test.expr)
+		if err != nil {
+			T.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		var names []string
+		StringReader(data, config).Do(filter.Do(func(r Row) bool {
+			names = append(names, r.Fields[0])
+			return true
+		}))
+		if out := strings.Join(names, ","); out != test.expect {
+			T.Errorf("%s: matched %q (!= %q)", test.expr, out, test.expect)
+		}
+	}
+}
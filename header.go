@@ -0,0 +1,63 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: header.go
+*  Description: Named columns of CSV data.
+ */
+import (
+	"errors"
+	"fmt"
+)
+
+//  Returned when an operation requires a Reader with a header.
+var ErrorNoHeader = errors.New("Input has no header.")
+
+//  The column names of CSV data, normally taken from its first row.
+type Header []string
+
+//  Returns the index of the first column with the given name, or -1 if no
+//  column has that name.
+func (h Header) Index(name string) int {
+	for i, col := range h {
+		if col == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//  Returns the indices of the named columns. Returns a *ColumnError if
+//  any name is not in the Header.
+func (h Header) Indices(names ...string) ([]int, error) {
+	var indices = make([]int, len(names))
+	for i, name := range names {
+		if indices[i] = h.Index(name); indices[i] < 0 {
+			return nil, &ColumnError{name}
+		}
+	}
+	return indices, nil
+}
+
+//  The error for a column name that is not in a Header.
+type ColumnError struct {
+	Name string
+}
+
+func (e *ColumnError) Error() string {
+	return fmt.Sprintf("No column named %q.", e.Name)
+}
+
+//  Returns the field in the column with the given name. The second return
+//  value is false if the row has no Header, the Header has no such column,
+//  or the row is too short to contain it.
+func (r Row) Get(name string) (string, bool) {
+	var i = r.Header.Index(name)
+	if i < 0 || i >= len(r.Fields) {
+		return "", false
+	}
+	return r.Fields[i], true
+}
@@ -0,0 +1,430 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: join.go
+*  Description: Joining two CSV streams on key columns.
+ */
+import (
+	"errors"
+	"strings"
+)
+
+//  The kind of join performed by HashJoin and MergeJoin.
+type JoinType int
+
+const (
+	InnerJoin JoinType = iota // Only rows with matching keys.
+	LeftJoin                  // Also left rows without a match.
+	RightJoin                 // Also right rows without a match.
+	FullJoin                  // Also rows without a match from either side.
+)
+
+//  Options controlling HashJoin and MergeJoin.
+type JoinOptions struct {
+	Type JoinType
+	//  Key columns of the left input.
+	LeftKeys []string
+	//  Key columns of the right input (LeftKeys if nil).
+	RightKeys []string
+	//  Prefixes added to non-key columns whose names appear in both inputs.
+	//  They default to "left_" and "right_".
+	LeftPrefix, RightPrefix string
+	//  Renames output columns (after prefixes are added).
+	Rename map[string]string
+}
+
+var (
+	ErrorJoinKeys = errors.New("Left and right join keys differ in number.")
+	ErrorUnsorted = errors.New("Input is not sorted by key.")
+)
+
+//  Join two inputs, building an in-memory hash table from the smaller
+//  one. Rows are read from both Readers alternately until one is
+//  exhausted, so memory use is bounded by about twice the size of the
+//  smaller input. Both Readers must have headers. The output header
+//  holds the key columns (named as in the left input), the other left
+//  columns and then the other right columns. Fields of a missing side
+//  are written as the Writer's Null. Rows are written in the order of
+//  the larger input, followed by unmatched rows of the smaller one.
+func HashJoin(w *Writer, left, right *Reader, opts *JoinOptions) error {
+	var j, err = newJoiner(w, left, right, opts)
+	if err != nil {
+		return err
+	}
+	if err = j.writeHeader(); err != nil {
+		return err
+	}
+
+	// Read alternately until one side ends. That side is built.
+	var (
+		bufs  [2][]Row
+		build = -1
+		rs    = [2]*Reader{left, right}
+	)
+	for build < 0 {
+		for side := 0; side < 2; side++ {
+			var r = rs[side].ReadRow()
+			if r.HasEOF() {
+				build = side
+				break
+			} else if r.HasError() {
+				return r.Error
+			}
+			bufs[side] = append(bufs[side], r)
+		}
+	}
+	var (
+		probe   = 1 - build
+		table   = make(map[string][]int)
+		matched = make([]bool, len(bufs[build]))
+	)
+	for i, r := range bufs[build] {
+		var key, err = j.key(build, r)
+		if err != nil {
+			return err
+		}
+		table[key] = append(table[key], i)
+	}
+	var probeRow = func(r Row) error {
+		var key, err = j.key(probe, r)
+		if err != nil {
+			return err
+		}
+		var matches = table[key]
+		for _, i := range matches {
+			matched[i] = true
+			if err = j.emitPair(probe, r.Fields, bufs[build][i].Fields); err != nil {
+				return err
+			}
+		}
+		if len(matches) == 0 && j.outer(probe) {
+			return j.emitPair(probe, r.Fields, nil)
+		}
+		return nil
+	}
+	for _, r := range bufs[probe] {
+		if err = probeRow(r); err != nil {
+			return err
+		}
+	}
+	bufs[probe] = nil
+	for {
+		var r = rs[probe].ReadRow()
+		if r.HasEOF() {
+			break
+		} else if r.HasError() {
+			return r.Error
+		}
+		if err = probeRow(r); err != nil {
+			return err
+		}
+	}
+	if j.outer(build) {
+		for i, r := range bufs[build] {
+			if !matched[i] {
+				if err = j.emitPair(build, r.Fields, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//  Join two inputs that are both sorted by their keys in lexical order
+//  (as Sort orders SortString keys), holding only rows with equal keys in
+//  memory. Returns a *LineError with ErrorUnsorted if an input is out of
+//  order. The output is like that of HashJoin, in key order.
+func MergeJoin(w *Writer, left, right *Reader, opts *JoinOptions) error {
+	var j, err = newJoiner(w, left, right, opts)
+	if err != nil {
+		return err
+	}
+	if err = j.writeHeader(); err != nil {
+		return err
+	}
+	var groups [2]*joinGroup
+	for side, csvr := range []*Reader{left, right} {
+		groups[side] = &joinGroup{j: j, side: side, csvr: csvr}
+		if err = groups[side].advance(); err != nil {
+			return err
+		}
+	}
+	var l, r = groups[0], groups[1]
+	for !l.eof || !r.eof {
+		var cmp int
+		switch {
+		case l.eof:
+			cmp = 1
+		case r.eof:
+			cmp = -1
+		default:
+			cmp = strings.Compare(l.key, r.key)
+		}
+		switch {
+		case cmp < 0:
+			if err = j.emitUnmatched(l); err != nil {
+				return err
+			}
+			err = l.advance()
+		case cmp > 0:
+			if err = j.emitUnmatched(r); err != nil {
+				return err
+			}
+			err = r.advance()
+		default:
+			for _, lrow := range l.rows {
+				for _, rrow := range r.rows {
+					if err = j.emit(lrow, rrow); err != nil {
+						return err
+					}
+				}
+			}
+			if err = l.advance(); err == nil {
+				err = r.advance()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type joiner struct {
+	w        *Writer
+	opts     *JoinOptions
+	headers  [2]Header
+	keys     [2][]int
+	nonkeys  [2][]int // Indices of non-key columns.
+	row      []string
+	keyPrint []string
+}
+
+func newJoiner(w *Writer, left, right *Reader, opts *JoinOptions) (*joiner, error) {
+	if opts == nil {
+		opts = new(JoinOptions)
+	}
+	var (
+		j         = &joiner{w: w, opts: opts}
+		rightKeys = opts.RightKeys
+		err       error
+	)
+	if rightKeys == nil {
+		rightKeys = opts.LeftKeys
+	}
+	if len(rightKeys) != len(opts.LeftKeys) {
+		return nil, ErrorJoinKeys
+	}
+	for side, csvr := range []*Reader{left, right} {
+		if j.headers[side], err = csvr.Header(); err != nil {
+			return nil, err
+		}
+		if j.headers[side] == nil {
+			return nil, ErrorNoHeader
+		}
+		var names = opts.LeftKeys
+		if side == 1 {
+			names = rightKeys
+		}
+		if j.keys[side], err = j.headers[side].Indices(names...); err != nil {
+			return nil, err
+		}
+		for i := range j.headers[side] {
+			if !containsInt(j.keys[side], i) {
+				j.nonkeys[side] = append(j.nonkeys[side], i)
+			}
+		}
+	}
+	j.keyPrint = make([]string, len(opts.LeftKeys))
+	return j, nil
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *joiner) outer(side int) bool {
+	switch j.opts.Type {
+	case FullJoin:
+		return true
+	case LeftJoin:
+		return side == 0
+	case RightJoin:
+		return side == 1
+	}
+	return false
+}
+
+func (j *joiner) writeHeader() error {
+	var (
+		names  []string
+		counts = make(map[string]int)
+	)
+	for side := 0; side < 2; side++ {
+		for _, i := range j.nonkeys[side] {
+			counts[j.headers[side][i]]++
+		}
+	}
+	for _, i := range j.keys[0] {
+		names = append(names, j.headers[0][i])
+	}
+	for side, prefix := range []string{j.opts.LeftPrefix, j.opts.RightPrefix} {
+		if prefix == "" {
+			prefix = []string{"left_", "right_"}[side]
+		}
+		for _, i := range j.nonkeys[side] {
+			var name = j.headers[side][i]
+			if counts[name] > 1 {
+				name = prefix + name
+			}
+			names = append(names, name)
+		}
+	}
+	for i, name := range names {
+		if rename, ok := j.opts.Rename[name]; ok {
+			names[i] = rename
+		}
+	}
+	var _, err = j.w.WriteRow(names...)
+	return err
+}
+
+//  The key of a row on one side, as a single string.
+func (j *joiner) key(side int, r Row) (string, error) {
+	for i, k := range j.keys[side] {
+		if k >= len(r.Fields) {
+			return "", &LineError{Line: r.Line, Column: j.headers[side][k], Err: ErrorIndex}
+		}
+		j.keyPrint[i] = r.Fields[k]
+	}
+	return strings.Join(j.keyPrint, "\x00"), nil
+}
+
+//  Emit a row given fields from one side and, possibly, the other.
+func (j *joiner) emitPair(side int, fields, other []string) error {
+	if side == 0 {
+		return j.emit(fields, other)
+	}
+	return j.emit(other, fields)
+}
+
+//  Write an output row. Either side may be nil for outer joins.
+func (j *joiner) emit(left, right []string) error {
+	var (
+		row   = j.row[:0]
+		null  = j.w.Null
+		field = func(fields []string, i int) string {
+			if i < len(fields) {
+				return fields[i]
+			}
+			return null
+		}
+	)
+	for k := range j.keys[0] {
+		if left != nil {
+			row = append(row, field(left, j.keys[0][k]))
+		} else {
+			row = append(row, field(right, j.keys[1][k]))
+		}
+	}
+	for side, fields := range [][]string{left, right} {
+		for _, i := range j.nonkeys[side] {
+			if fields == nil {
+				row = append(row, null)
+			} else {
+				row = append(row, field(fields, i))
+			}
+		}
+	}
+	j.row = row
+	var _, err = j.w.WriteRow(row...)
+	return err
+}
+
+func (j *joiner) emitUnmatched(g *joinGroup) error {
+	if !j.outer(g.side) {
+		return nil
+	}
+	for _, fields := range g.rows {
+		if err := j.emitPair(g.side, fields, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//  Consecutive rows of a sorted input with equal keys.
+type joinGroup struct {
+	j    *joiner
+	side int
+	csvr *Reader
+	key  string
+	rows [][]string
+	next *Row // First row of the following group.
+	eof  bool
+}
+
+//  Read the next group of rows.
+func (g *joinGroup) advance() error {
+	g.rows = g.rows[:0]
+	if g.next == nil {
+		var r, err = g.read()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			g.eof = true
+			return nil
+		}
+		g.next = r
+	}
+	var key, err = g.j.key(g.side, *g.next)
+	if err != nil {
+		return err
+	}
+	g.key = key
+	g.rows = append(g.rows, g.next.Fields)
+	for {
+		var r, err = g.read()
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			g.next = nil
+			return nil
+		}
+		var k string
+		if k, err = g.j.key(g.side, *r); err != nil {
+			return err
+		}
+		if k < g.key {
+			return &LineError{Line: r.Line, Err: ErrorUnsorted}
+		}
+		if k != g.key {
+			g.next = r
+			return nil
+		}
+		g.rows = append(g.rows, r.Fields)
+	}
+}
+
+//  Read a row, returning nil at the end of input.
+func (g *joinGroup) read() (*Row, error) {
+	var r = g.csvr.ReadRow()
+	if r.HasEOF() {
+		return nil, nil
+	} else if r.HasError() {
+		return nil, r.Error
+	}
+	return &r, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+var (
+	testJoinLeft  = "id,name,city\n1,alice,Paris\n2,bob,Rome\n2,bobby,Rome\n4,dana,Oslo\n"
+	testJoinRight = "uid,city,amount\n2,Milan,10\n3,Lima,5\n4,Oslo,7\n4,Bergen,8\n"
+)
+
+func testJoin(T *testing.T, join func(*Writer, *Reader, *Reader, *JoinOptions) error,
+	typ JoinType) []string {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "-"
+	var (
+		left  = StringReader(testJoinLeft, config)
+		right = StringReader(testJoinRight, config)
+		w, b  = BufferWriter(config)
+		opts  = &JoinOptions{
+			Type:      typ,
+			LeftKeys:  []string{"id"},
+			RightKeys: []string{"uid"},
+			Rename:    map[string]string{"right_city": "to"}}
+	)
+	if err := join(w, left, right, opts); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	var lines = strings.Split(strings.TrimSpace(b.String()), "\n")
+	if lines[0] != "id,name,left_city,to,amount" {
+		T.Errorf("Unexpected header %q", lines[0])
+	}
+	sort.Strings(lines[1:])
+	return lines[1:]
+}
+
+func TestJoin(T *testing.T) {
+	var tests = []struct {
+		typ    JoinType
+		expect string
+	}{
+		{InnerJoin, "2,bob,Rome,Milan,10;2,bobby,Rome,Milan,10;4,dana,Oslo,Bergen,8;4,dana,Oslo,Oslo,7"},
+		{LeftJoin, "1,alice,Paris,-,-;2,bob,Rome,Milan,10;2,bobby,Rome,Milan,10;4,dana,Oslo,Bergen,8;4,dana,Oslo,Oslo,7"},
+		{RightJoin, "2,bob,Rome,Milan,10;2,bobby,Rome,Milan,10;3,-,-,Lima,5;4,dana,Oslo,Bergen,8;4,dana,Oslo,Oslo,7"},
+		{FullJoin, "1,alice,Paris,-,-;2,bob,Rome,Milan,10;2,bobby,Rome,Milan,10;3,-,-,Lima,5;4,dana,Oslo,Bergen,8;4,dana,Oslo,Oslo,7"},
+	}
+	for _, test := range tests {
+		if out := strings.Join(testJoin(T, HashJoin, test.typ), ";"); out != test.expect {
+			T.Errorf("HashJoin %d:\n%q\n(!= %q)", test.typ, out, test.expect)
+		}
+		if out := strings.Join(testJoin(T, MergeJoin, test.typ), ";"); out != test.expect {
+			T.Errorf("MergeJoin %d:\n%q\n(!= %q)", test.typ, out, test.expect)
+		}
+	}
+}
+
+func TestMergeJoinUnsorted(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		left  = StringReader("k,a\n1,x\n3,y\n2,z\n", config)
+		right = StringReader("k,b\n1,x\n", config)
+		w, _  = BufferWriter(config)
+		err   = MergeJoin(w, left, right, &JoinOptions{LeftKeys: []string{"k"}})
+	)
+	if lerr, ok := err.(*LineError); !ok || lerr.Err != ErrorUnsorted || lerr.Line != 4 {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
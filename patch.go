@@ -0,0 +1,341 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: patch.go
+*  Description: Applying patch CSV data to a base file.
+ */
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+//  How a conflicting patch row is handled.
+type ConflictAction int
+
+const (
+	ConflictFail  ConflictAction = iota // Stop and return the conflict.
+	ConflictSkip                        // Ignore the patch row.
+	ConflictForce                       // Insert or overwrite anyway.
+)
+
+//  Returned for patch rows that modify or remove keys missing from the
+//  base.
+var ErrorMissingKey = errors.New("Key not found.")
+
+//  A patch row that can not be applied as given.
+type PatchConflict struct {
+	Op   ChangeOp
+	Key  []string
+	Line int   // Line of the patch row.
+	Err  error // ErrorMissingKey or ErrorDuplicateKey.
+}
+
+//  Options controlling Patch.
+type PatchOptions struct {
+	//  Configuration of the inputs and output (DefaultConfig if nil).
+	Config *Config
+	//  Key columns, present in both the base and the patch.
+	Keys []string
+	//  Both inputs are sorted by key in lexical order (see MergeJoin), and
+	//  are merged without holding either in memory. Otherwise the patch is
+	//  held in memory, indexed by key.
+	Sorted bool
+	//  Decides how to handle a conflict. When nil, every conflict fails.
+	//  Forcing an added row with an existing key replaces the base row,
+	//  forcing a modified row with a missing key adds it, and forcing a
+	//  removal of a missing key ignores it.
+	OnConflict func(c *PatchConflict) ConflictAction
+}
+
+//  Apply patch CSV data, like that written by DiffResult.WritePatch, to a
+//  base input and write the result to out. Both inputs must have headers.
+//  The output has the base header. Fields of patch columns not in the base
+//  are dropped, and base columns not in the patch keep their values (or
+//  are the Config's Null in added rows). With opts.Sorted, added rows are
+//  merged in key order. Otherwise, they are written after the base rows.
+//  Conflicts that fail are returned as a *LineError
+//  for the patch row.
+func Patch(out io.Writer, base, patch io.Reader, opts *PatchOptions) error {
+	if opts == nil {
+		opts = new(PatchOptions)
+	}
+	var config = NewConfig()
+	if opts.Config != nil {
+		*config = *opts.Config
+	}
+	config.HasHeader = true
+	var (
+		p = &patcher{
+			opts:  opts,
+			baser: NewReader(base, config),
+			patr:  NewReader(patch, config),
+			w:     NewWriter(out, config)}
+		err error
+	)
+	if err = p.init(); err != nil {
+		return err
+	}
+	if _, err = p.w.WriteRow(p.baseHeader...); err != nil {
+		return err
+	}
+	if opts.Sorted {
+		err = p.merge()
+	} else {
+		err = p.index()
+	}
+	if err != nil {
+		return err
+	}
+	return p.w.Flush()
+}
+
+type patcher struct {
+	opts        *PatchOptions
+	baser, patr *Reader
+	w           *Writer
+	baseHeader  Header
+	baseKeys    []int
+	patchKeys   []int
+	op          int   // Index of the op column in the patch.
+	columns     []int // Base index of each patch column (or -1).
+}
+
+//  A parsed patch row.
+type patchRow struct {
+	op     ChangeOp
+	key    string
+	fields []string
+	line   int
+	used   bool
+}
+
+func (p *patcher) init() error {
+	var (
+		patchHeader Header
+		err         error
+	)
+	if p.baseHeader, err = p.baser.Header(); err != nil {
+		return err
+	}
+	if patchHeader, err = p.patr.Header(); err != nil {
+		return err
+	}
+	if p.baseKeys, err = p.baseHeader.Indices(p.opts.Keys...); err != nil {
+		return err
+	}
+	if p.patchKeys, err = patchHeader.Indices(p.opts.Keys...); err != nil {
+		return err
+	}
+	if p.op = patchHeader.Index(PatchOpColumn); p.op < 0 {
+		return &ColumnError{PatchOpColumn}
+	}
+	p.columns = make([]int, len(patchHeader))
+	for i, name := range patchHeader {
+		p.columns[i] = p.baseHeader.Index(name)
+	}
+	p.columns[p.op] = -1
+	return nil
+}
+
+//  Read the next patch row, or nil at the end of the patch.
+func (p *patcher) readPatch() (*patchRow, error) {
+	var r = p.patr.ReadRow()
+	if r.HasEOF() {
+		return nil, nil
+	} else if r.HasError() {
+		return nil, r.Error
+	}
+	if p.op >= len(r.Fields) {
+		return nil, &LineError{Line: r.Line, Column: PatchOpColumn, Err: ErrorIndex}
+	}
+	var op, err = ParseChangeOp(r.Fields[p.op])
+	if err != nil {
+		return nil, &LineError{Line: r.Line, Column: PatchOpColumn, Err: err}
+	}
+	var key string
+	if key, err = rowKey(r, p.patchKeys, p.patr.header); err != nil {
+		return nil, err
+	}
+	return &patchRow{op: op, key: key, fields: r.Fields, line: r.Line}, nil
+}
+
+//  Read the next base row and its key, or nil at the end of the base.
+func (p *patcher) readBase() ([]string, string, error) {
+	var r = p.baser.ReadRow()
+	if r.HasEOF() {
+		return nil, "", nil
+	} else if r.HasError() {
+		return nil, "", r.Error
+	}
+	var key, err = rowKey(r, p.baseKeys, p.baseHeader)
+	return r.Fields, key, err
+}
+
+//  Decide how to handle a conflict.
+func (p *patcher) conflict(pr *patchRow, err error) (ConflictAction, error) {
+	var action = ConflictFail
+	if p.opts.OnConflict != nil {
+		var c = &PatchConflict{
+			Op: pr.op, Key: strings.Split(pr.key, "\x00"), Line: pr.line, Err: err}
+		action = p.opts.OnConflict(c)
+	}
+	if action == ConflictFail {
+		return action, &LineError{Line: pr.line, Err: err}
+	}
+	return action, nil
+}
+
+//  Write a base row with the patch applied. The base row is nil for added
+//  rows.
+func (p *patcher) write(base []string, pr *patchRow) error {
+	var row = make([]string, len(p.baseHeader))
+	for i := range row {
+		row[i] = fieldOr(base, i, p.w.Null)
+		if base == nil {
+			row[i] = p.w.Null
+		}
+	}
+	if pr != nil {
+		for i, j := range p.columns {
+			if j >= 0 && i < len(pr.fields) {
+				row[j] = pr.fields[i]
+			}
+		}
+	}
+	var _, err = p.w.WriteRow(row...)
+	return err
+}
+
+//  Apply a patch row to a base row with the same key.
+func (p *patcher) applyMatch(base []string, pr *patchRow) error {
+	switch pr.op {
+	case Removed:
+		return nil
+	case Added:
+		var action, err = p.conflict(pr, ErrorDuplicateKey)
+		if err != nil {
+			return err
+		}
+		if action == ConflictSkip {
+			return p.write(base, nil)
+		}
+		// Forced: the added row replaces the base row entirely.
+		return p.write(nil, pr)
+	}
+	return p.write(base, pr)
+}
+
+//  Apply a patch row whose key is not in the base.
+func (p *patcher) applyMissing(pr *patchRow) error {
+	if pr.op == Added {
+		return p.write(nil, pr)
+	}
+	var action, err = p.conflict(pr, ErrorMissingKey)
+	if err != nil {
+		return err
+	}
+	if action == ConflictForce && pr.op == Modified {
+		return p.write(nil, pr)
+	}
+	return nil
+}
+
+//  Patch an unsorted base using an index of the patch.
+func (p *patcher) index() error {
+	var (
+		index = make(map[string]*patchRow)
+		order []*patchRow
+	)
+	for {
+		var pr, err = p.readPatch()
+		if err != nil {
+			return err
+		}
+		if pr == nil {
+			break
+		}
+		if _, ok := index[pr.key]; ok {
+			return &LineError{Line: pr.line, Err: ErrorDuplicateKey}
+		}
+		index[pr.key] = pr
+		order = append(order, pr)
+	}
+	for {
+		var fields, key, err = p.readBase()
+		if err != nil {
+			return err
+		}
+		if fields == nil {
+			break
+		}
+		var pr = index[key]
+		if pr == nil {
+			err = p.write(fields, nil)
+		} else {
+			pr.used = true
+			err = p.applyMatch(fields, pr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, pr := range order {
+		if !pr.used {
+			if err := p.applyMissing(pr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//  Patch a sorted base by merging it with the sorted patch.
+func (p *patcher) merge() error {
+	var (
+		fields, key, err = p.readBase()
+		pr               *patchRow
+	)
+	if err == nil {
+		pr, err = p.readPatch()
+	}
+	var nextBase = func() {
+		var last = key
+		if fields, key, err = p.readBase(); err == nil && fields != nil && key < last {
+			err = &LineError{Line: p.baser.LineNum(), Err: ErrorUnsorted}
+		}
+	}
+	var nextPatch = func() {
+		var last = pr.key
+		if pr, err = p.readPatch(); err == nil && pr != nil {
+			if pr.key < last {
+				err = &LineError{Line: pr.line, Err: ErrorUnsorted}
+			} else if pr.key == last {
+				err = &LineError{Line: pr.line, Err: ErrorDuplicateKey}
+			}
+		}
+	}
+	for err == nil && (fields != nil || pr != nil) {
+		switch {
+		case pr == nil || fields != nil && key < pr.key:
+			if err = p.write(fields, nil); err == nil {
+				nextBase()
+			}
+		case fields == nil || pr.key < key:
+			if err = p.applyMissing(pr); err == nil {
+				nextPatch()
+			}
+		default:
+			if err = p.applyMatch(fields, pr); err == nil {
+				if nextBase(); err == nil {
+					nextPatch()
+				}
+			}
+		}
+	}
+	return err
+}
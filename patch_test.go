@@ -0,0 +1,82 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var (
+	testPatchBase = "id,name,qty\n1,apple,5\n2,pear,3\n3,fig,0\n"
+	testPatch     = "op,id,qty,note\nupdate,2,4,x\ndelete,3,,\ninsert,4,7,y\n"
+)
+
+func TestPatch(T *testing.T) {
+	var expect = "id,name,qty\n1,apple,5\n2,pear,4\n4,,7\n"
+	for _, sorted := range []bool{false, true} {
+		var out bytes.Buffer
+		var err = Patch(&out, strings.NewReader(testPatchBase), strings.NewReader(testPatch),
+			&PatchOptions{Keys: []string{"id"}, Sorted: sorted})
+		if err != nil {
+			T.Errorf("sorted=%v: %v", sorted, err)
+		} else if out.String() != expect {
+			T.Errorf("sorted=%v: unexpected output\n%s\n(!=\n%s)", sorted, out.String(), expect)
+		}
+	}
+}
+
+func TestPatchDiff(T *testing.T) {
+	var newData = "qty,id,name\n5,1,apple\n4,2,pear\n7,4,kiwi\n"
+	var d, err = Diff(strings.NewReader(testPatchBase), strings.NewReader(newData),
+		[]string{"id"}, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	var w, patch = BufferWriter(nil)
+	d.WritePatch(w)
+	w.Flush()
+	var out bytes.Buffer
+	if err = Patch(&out, strings.NewReader(testPatchBase), patch,
+		&PatchOptions{Keys: []string{"id"}}); err != nil {
+		T.Fatal(err)
+	}
+	if expect := "id,name,qty\n1,apple,5\n2,pear,4\n4,kiwi,7\n"; out.String() != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out.String(), expect)
+	}
+}
+
+func TestPatchConflicts(T *testing.T) {
+	var patch = "op,id,name\ninsert,1,apricot\ndelete,8,\nupdate,9,plum\n"
+	for _, sorted := range []bool{false, true} {
+		var (
+			out  bytes.Buffer
+			opts = &PatchOptions{Keys: []string{"id"}, Sorted: sorted}
+			err  = Patch(&out, strings.NewReader(testPatchBase), strings.NewReader(patch), opts)
+		)
+		if lerr, ok := err.(*LineError); !ok || lerr.Err != ErrorDuplicateKey || lerr.Line != 2 {
+			T.Errorf("sorted=%v: unexpected error %v", sorted, err)
+		}
+
+		var conflicts []error
+		opts.OnConflict = func(c *PatchConflict) ConflictAction {
+			conflicts = append(conflicts, c.Err)
+			return ConflictForce
+		}
+		out.Reset()
+		err = Patch(&out, strings.NewReader(testPatchBase), strings.NewReader(patch), opts)
+		if err != nil {
+			T.Fatal(err)
+		}
+		if len(conflicts) != 3 || conflicts[0] != ErrorDuplicateKey || conflicts[1] != ErrorMissingKey {
+			T.Errorf("sorted=%v: unexpected conflicts %v", sorted, conflicts)
+		}
+		// The forced insert replaces row 1, so its qty is NULL.
+		if expect := "id,name,qty\n1,apricot,\n2,pear,3\n3,fig,0\n9,plum,\n"; out.String() != expect {
+			T.Errorf("sorted=%v: unexpected output\n%s\n(!=\n%s)", sorted, out.String(), expect)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: pipeline.go
+*  Description: Composable transformations from a Reader to a Writer.
+ */
+import (
+	"strings"
+)
+
+//  A step of a Pipeline. Stages resolve column names when Header is
+//  called, so a Stage should be used by only one Pipeline run at a time.
+type Stage interface {
+	//  Returns the header of the stage's output given that of its input.
+	Header(in Header) (Header, error)
+	//  Transforms a row whose Header is the stage's input header. Returns
+	//  the output fields and false if the row is to be dropped.
+	Apply(r Row) ([]string, bool, error)
+}
+
+//  A sequence of Stages transforming the rows read from a Reader before
+//  they are written to a Writer.
+type Pipeline struct {
+	stages []Stage
+}
+
+//  Create a Pipeline of the given stages.
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages}
+}
+
+//  Append stages to the pipeline. Returns the pipeline.
+func (p *Pipeline) Add(stages ...Stage) *Pipeline {
+	p.stages = append(p.stages, stages...)
+	return p
+}
+
+//  Read rows from r, pass them through each stage and write them with w.
+//  The Reader must have a header, which is propagated through the stages
+//  and written before any row. Errors from stages are returned as a
+//  *LineError holding the line of the source row. The Writer is flushed.
+func (p *Pipeline) Run(r *Reader, w *Writer) error {
+	var header, err = r.Header()
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return ErrorNoHeader
+	}
+	var headers = make([]Header, len(p.stages)+1)
+	headers[0] = header
+	for i, s := range p.stages {
+		if headers[i+1], err = s.Header(headers[i]); err != nil {
+			return err
+		}
+	}
+	if _, err = w.WriteRow(headers[len(p.stages)]...); err != nil {
+		return err
+	}
+	r.Do(func(row Row) bool {
+		if err = row.Error; err != nil {
+			return false
+		}
+		var keep = true
+		for i, s := range p.stages {
+			row.Header = headers[i]
+			if row.Fields, keep, err = s.Apply(row); err != nil {
+				if _, ok := err.(*LineError); !ok {
+					err = &LineError{Line: row.Line, Err: err}
+				}
+				return false
+			}
+			if !keep {
+				return true
+			}
+		}
+		_, err = w.WriteRow(row.Fields...)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+//  Fields at the given indices, or def for indices past the end of the
+//  row.
+func projectFields(fields []string, indices []int, def string) []string {
+	var out = make([]string, len(indices))
+	for i, j := range indices {
+		out[i] = fieldOr(fields, j, def)
+	}
+	return out
+}
+
+type projectStage struct {
+	names   func(in Header) ([]string, error)
+	indices []int
+}
+
+func (s *projectStage) Header(in Header) (Header, error) {
+	var names, err = s.names(in)
+	if err != nil {
+		return nil, err
+	}
+	if s.indices, err = in.Indices(names...); err != nil {
+		return nil, err
+	}
+	return Header(names), nil
+}
+
+func (s *projectStage) Apply(r Row) ([]string, bool, error) {
+	return projectFields(r.Fields, s.indices, ""), true, nil
+}
+
+//  A stage keeping only the named columns, in the given order.
+func SelectStage(columns ...string) Stage {
+	return &projectStage{names: func(in Header) ([]string, error) {
+		return columns, nil
+	}}
+}
+
+//  A stage moving the named columns, in the given order, before the other
+//  columns.
+func ReorderStage(columns ...string) Stage {
+	return &projectStage{names: func(in Header) ([]string, error) {
+		var names = append([]string(nil), columns...)
+		for _, name := range in {
+			if !containsString(columns, name) {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}}
+}
+
+//  A stage removing the named columns.
+func DropStage(columns ...string) Stage {
+	return &projectStage{names: func(in Header) ([]string, error) {
+		var names []string
+		for _, name := range columns {
+			if in.Index(name) < 0 {
+				return nil, &ColumnError{name}
+			}
+		}
+		for _, name := range in {
+			if !containsString(columns, name) {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}}
+}
+
+type renameStage map[string]string
+
+//  A stage renaming columns (old names are keys of the map).
+func RenameStage(names map[string]string) Stage {
+	return renameStage(names)
+}
+
+func (s renameStage) Header(in Header) (Header, error) {
+	var out = make(Header, len(in))
+	for name := range s {
+		if in.Index(name) < 0 {
+			return nil, &ColumnError{name}
+		}
+	}
+	for i, name := range in {
+		if rename, ok := s[name]; ok {
+			name = rename
+		}
+		out[i] = name
+	}
+	return out, nil
+}
+
+func (s renameStage) Apply(r Row) ([]string, bool, error) {
+	return r.Fields, true, nil
+}
+
+type filterStage func(Row) bool
+
+//  A stage keeping only rows for which keep returns true, such as the
+//  Match method of a Filter.
+func FilterStage(keep func(Row) bool) Stage {
+	return filterStage(keep)
+}
+
+func (s filterStage) Header(in Header) (Header, error) { return in, nil }
+
+func (s filterStage) Apply(r Row) ([]string, bool, error) {
+	return r.Fields, s(r), nil
+}
+
+type mapStage func(Row) ([]string, error)
+
+//  A stage replacing the fields of each row with those returned by f,
+//  without changing the header.
+func MapStage(f func(Row) ([]string, error)) Stage {
+	return mapStage(f)
+}
+
+func (s mapStage) Header(in Header) (Header, error) { return in, nil }
+
+func (s mapStage) Apply(r Row) ([]string, bool, error) {
+	var fields, err = s(r)
+	return fields, err == nil, err
+}
+
+type addColumnStage struct {
+	name  string
+	value func(Row) (string, error)
+	width int
+}
+
+//  A stage appending a column computed by value from each row.
+func AddColumnStage(name string, value func(Row) (string, error)) Stage {
+	return &addColumnStage{name: name, value: value}
+}
+
+func (s *addColumnStage) Header(in Header) (Header, error) {
+	s.width = len(in)
+	return append(append(Header(nil), in...), s.name), nil
+}
+
+func (s *addColumnStage) Apply(r Row) ([]string, bool, error) {
+	var v, err = s.value(r)
+	if err != nil {
+		return nil, false, &LineError{Line: r.Line, Column: s.name, Err: err}
+	}
+	var fields = make([]string, s.width, s.width+1)
+	copy(fields, r.Fields)
+	return append(fields, v), true, nil
+}
+
+type dedupStage struct {
+	columns []string
+	indices []int
+	seen    map[string]bool
+}
+
+//  A stage dropping rows whose values in the named columns (all columns if
+//  none are named) match those of an earlier row. The values of every
+//  distinct row are held in memory.
+func DedupStage(columns ...string) Stage {
+	return &dedupStage{columns: columns}
+}
+
+func (s *dedupStage) Header(in Header) (Header, error) {
+	var err error
+	s.seen = make(map[string]bool)
+	s.indices = nil
+	if len(s.columns) > 0 {
+		s.indices, err = in.Indices(s.columns...)
+	}
+	return in, err
+}
+
+func (s *dedupStage) Apply(r Row) ([]string, bool, error) {
+	var key string
+	if s.indices == nil {
+		key = strings.Join(r.Fields, "\x00")
+	} else {
+		key = strings.Join(projectFields(r.Fields, s.indices, ""), "\x00")
+	}
+	if s.seen[key] {
+		return r.Fields, false, nil
+	}
+	s.seen[key] = true
+	return r.Fields, true, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader("name,age,secret,city\nalice,34,x,Paris\nbob,17,y,Rome\nalice,34,z,Paris\ncarl,52,w,Oslo\n", config)
+		w, b = BufferWriter(config)
+		p    = NewPipeline(
+			DropStage("secret"),
+			FilterStage(MustCompileFilter("age >= 18").Match),
+			DedupStage(),
+			AddColumnStage("decade", func(r Row) (string, error) {
+				var age, _ = r.Get("age")
+				return age[:1] + "0s", nil
+			}),
+			MapStage(func(r Row) ([]string, error) {
+				var fields = append([]string(nil), r.Fields...)
+				fields[0] = strings.ToUpper(fields[0])
+				return fields, nil
+			}),
+			RenameStage(map[string]string{"name": "NAME"}),
+			ReorderStage("city"),
+			SelectStage("city", "NAME", "decade"))
+	)
+	if err := p.Run(r, w); err != nil {
+		T.Fatal(err)
+	}
+	var expect = "city,NAME,decade\nParis,ALICE,30s\nOslo,CARL,50s\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+}
+
+func TestPipelineErrors(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader("n\n1\n2\nx\n", config)
+		w, _ = BufferWriter(config)
+		p    = NewPipeline(MapStage(func(r Row) ([]string, error) {
+			if _, err := strconv.Atoi(r.Fields[0]); err != nil {
+				return nil, errors.New("not a number")
+			}
+			return r.Fields, nil
+		}))
+		err = p.Run(r, w)
+	)
+	if lerr, ok := err.(*LineError); !ok || lerr.Line != 4 {
+		T.Errorf("Unexpected error %v", err)
+	}
+	r = StringReader("n\n1\n", config)
+	if err = NewPipeline(SelectStage("m")).Run(r, w); err == nil {
+		T.Error("No error selecting a missing column")
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: profile.go
+*  Description: Per-column statistics of CSV data.
+ */
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+//  The type of the values of a column.
+type FieldType int
+
+const (
+	TypeString FieldType = iota
+	TypeInteger
+	TypeNumber
+	TypeBoolean
+	TypeDatetime
+)
+
+var fieldTypeNames = []string{"string", "integer", "number", "boolean", "datetime"}
+
+func (t FieldType) String() string {
+	if t < 0 || int(t) >= len(fieldTypeNames) {
+		return "FieldType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return fieldTypeNames[t]
+}
+
+//  Returned when parsing an unknown FieldType name.
+var ErrorTypeName = errors.New("Unknown field type name.")
+
+//  Parse the name of a FieldType, as returned by its String method.
+func ParseFieldType(s string) (FieldType, error) {
+	for i, name := range fieldTypeNames {
+		if s == name {
+			return FieldType(i), nil
+		}
+	}
+	return 0, ErrorTypeName
+}
+
+func (t FieldType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *FieldType) UnmarshalText(text []byte) error {
+	var err error
+	*t, err = ParseFieldType(string(text))
+	return err
+}
+
+//  Infers the type of a column from its non-NULL fields. The most specific
+//  type matching every field wins, in the order integer, number, boolean
+//  and datetime (in the first of the given layouts matching every field).
+//  Columns without fields are strings.
+type typeGuess struct {
+	layouts                  []string
+	seen                     bool
+	integer, number, boolean bool
+}
+
+func newTypeGuess(layouts ...string) *typeGuess {
+	return &typeGuess{layouts: append([]string(nil), layouts...), integer: true, number: true, boolean: true}
+}
+
+func (g *typeGuess) add(field string) {
+	field = strings.TrimSpace(field)
+	g.seen = true
+	if g.integer {
+		_, err := strconv.ParseInt(field, 10, 64)
+		g.integer = err == nil
+	}
+	if g.number {
+		_, g.number = parseFinite(field)
+	}
+	if g.boolean {
+		_, err := strconv.ParseBool(field)
+		g.boolean = err == nil
+	}
+	var layouts = g.layouts[:0]
+	for _, layout := range g.layouts {
+		if _, err := time.Parse(layout, field); err == nil {
+			layouts = append(layouts, layout)
+		}
+	}
+	g.layouts = layouts
+}
+
+//  Parse a finite number. NaN and infinities are not numbers here: they
+//  would poison the statistics of a column.
+func parseFinite(s string) (float64, bool) {
+	var x, err = strconv.ParseFloat(s, 64)
+	return x, err == nil && !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+func (g *typeGuess) Type() FieldType {
+	switch {
+	case !g.seen:
+		return TypeString
+	case g.integer:
+		return TypeInteger
+	case g.number:
+		return TypeNumber
+	case g.boolean:
+		return TypeBoolean
+	case len(g.layouts) > 0:
+		return TypeDatetime
+	}
+	return TypeString
+}
+
+//  The time layout of a datetime column, or the empty string.
+func (g *typeGuess) Layout() string {
+	if len(g.layouts) == 0 {
+		return ""
+	}
+	return g.layouts[0]
+}
+
+var (
+	//  The number of most frequent values reported for each column.
+	ProfileTopK = 5
+	//  The number of histogram bins of numeric columns.
+	ProfileBins = 10
+	//  The number of numeric values sampled for histograms.
+	ProfileSample = 1000
+)
+
+//  A value and the number of times it occurs.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+//  A histogram bin, counting values from Low up to High (inclusive for
+//  the last bin).
+type Bin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int     `json:"count"`
+}
+
+//  Statistics of one column.
+type ColumnProfile struct {
+	Name      string    `json:"name"`
+	Type      FieldType `json:"type"`
+	Count     int       `json:"count"` // Non-NULL fields.
+	Nulls     int       `json:"nulls"` // NULL, empty and missing fields.
+	Min       string    `json:"min,omitempty"`
+	Max       string    `json:"max,omitempty"`
+	Mean      float64   `json:"mean,omitempty"`   // Numbers only.
+	Stddev    float64   `json:"stddev,omitempty"` // Population deviation.
+	MinLength int       `json:"min_length"`
+	MaxLength int       `json:"max_length"`
+	//  Estimated number of distinct non-NULL fields.
+	Distinct  int          `json:"distinct"`
+	TopValues []ValueCount `json:"top_values,omitempty"`
+	Histogram []Bin        `json:"histogram,omitempty"`
+}
+
+//  Statistics of CSV data, as computed by Profile.
+type TableProfile struct {
+	Rows    int              `json:"rows"`
+	Columns []*ColumnProfile `json:"columns"`
+}
+
+//  Compute statistics for each column of the rows read from r in a single
+//  pass. Columns are named by r's header, or numbered from 1 without one.
+//  Memory does not depend on the number of rows: distinct counts are
+//  estimated with a HyperLogLog sketch, the top values are approximated
+//  with the Space-Saving algorithm (and are exact for columns with few
+//  distinct values), and histograms are built from a sample of at most
+//  ProfileSample values, scaled to the column's count.
+func Profile(r *Reader) (*TableProfile, error) {
+	var header, err = r.Header()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		p     = new(TableProfile)
+		stats []*columnStats
+		rnd   = rand.New(rand.NewSource(1))
+	)
+	var column = func(i int) *columnStats {
+		for len(stats) <= i {
+			var name = strconv.Itoa(len(stats) + 1)
+			if len(stats) < len(header) {
+				name = header[len(stats)]
+			}
+			var s = newColumnStats(name, r.TimeLayout)
+			s.nulls = p.Rows // Missing from earlier rows.
+			stats = append(stats, s)
+		}
+		return stats[i]
+	}
+	for i := range header {
+		column(i)
+	}
+	for row := r.ReadRow(); !row.HasEOF(); row = r.ReadRow() {
+		if row.HasError() {
+			return nil, row.Error
+		}
+		for i, field := range row.Fields {
+			var s = column(i)
+			if field == "" || r.IsNull(field) {
+				s.nulls++
+			} else {
+				s.add(field, rnd)
+			}
+		}
+		for _, s := range stats[len(row.Fields):] {
+			s.nulls++
+		}
+		p.Rows++
+	}
+	for _, s := range stats {
+		p.Columns = append(p.Columns, s.profile())
+	}
+	return p, nil
+}
+
+type columnStats struct {
+	name           string
+	guess          *typeGuess
+	count, nulls   int
+	minStr, maxStr string
+	minNum, maxNum float64
+	minTime        time.Time
+	maxTime        time.Time
+	mean, m2       float64 // Welford's running mean and squared deviation.
+	numbers        int     // Fields parsed as numbers.
+	minLen, maxLen int
+	distinct       *hyperLogLog
+	top            *spaceSaving
+	sample         []float64
+}
+
+func newColumnStats(name, layout string) *columnStats {
+	return &columnStats{
+		name:     name,
+		guess:    newTypeGuess(layout),
+		distinct: newHyperLogLog(),
+		top:      newSpaceSaving(4 * ProfileTopK)}
+}
+
+func (s *columnStats) add(field string, rnd *rand.Rand) {
+	var n = len([]rune(field))
+	if s.count == 0 || n < s.minLen {
+		s.minLen = n
+	}
+	if s.count == 0 || n > s.maxLen {
+		s.maxLen = n
+	}
+	if s.count == 0 || field < s.minStr {
+		s.minStr = field
+	}
+	if s.count == 0 || field > s.maxStr {
+		s.maxStr = field
+	}
+	s.count++
+	s.guess.add(field)
+	s.distinct.add(field)
+	s.top.add(field)
+	var trimmed = strings.TrimSpace(field)
+	if s.guess.number {
+		if x, ok := parseFinite(trimmed); ok {
+			if s.numbers == 0 || x < s.minNum {
+				s.minNum = x
+			}
+			if s.numbers == 0 || x > s.maxNum {
+				s.maxNum = x
+			}
+			s.numbers++
+			var delta = x - s.mean
+			s.mean += delta / float64(s.numbers)
+			s.m2 += delta * (x - s.mean)
+			// Reservoir sampling.
+			if len(s.sample) < ProfileSample {
+				s.sample = append(s.sample, x)
+			} else if j := rnd.Intn(s.numbers); j < ProfileSample {
+				s.sample[j] = x
+			}
+		}
+	}
+	if layout := s.guess.Layout(); layout != "" {
+		if t, err := time.Parse(layout, trimmed); err == nil {
+			if s.minTime.IsZero() || t.Before(s.minTime) {
+				s.minTime = t
+			}
+			if s.maxTime.IsZero() || t.After(s.maxTime) {
+				s.maxTime = t
+			}
+		}
+	}
+}
+
+func (s *columnStats) profile() *ColumnProfile {
+	var p = &ColumnProfile{
+		Name:      s.name,
+		Type:      s.guess.Type(),
+		Count:     s.count,
+		Nulls:     s.nulls,
+		Min:       s.minStr,
+		Max:       s.maxStr,
+		MinLength: s.minLen,
+		MaxLength: s.maxLen,
+		Distinct:  s.distinct.estimate(),
+		TopValues: s.top.top(ProfileTopK)}
+	switch p.Type {
+	case TypeInteger, TypeNumber:
+		var format = func(x float64) string {
+			return strconv.FormatFloat(x, FloatFmt, FloatPrec, 64)
+		}
+		p.Min, p.Max = format(s.minNum), format(s.maxNum)
+		// Numbers near the limits of a float64 can overflow the moments;
+		// they are left out rather than reported as infinite.
+		if p.Stddev = math.Sqrt(s.m2 / float64(s.numbers)); math.IsInf(p.Stddev, 0) || math.IsNaN(p.Stddev) {
+			p.Stddev = 0
+		}
+		if p.Mean = s.mean; math.IsInf(p.Mean, 0) || math.IsNaN(p.Mean) {
+			p.Mean, p.Stddev = 0, 0
+		}
+		p.Histogram = histogram(s.sample, s.minNum, s.maxNum, s.numbers)
+	case TypeDatetime:
+		p.Min = s.minTime.Format(s.guess.Layout())
+		p.Max = s.maxTime.Format(s.guess.Layout())
+	}
+	return p
+}
+
+//  A histogram of ProfileBins bins between min and max, built from a sample
+//  of n values.
+func histogram(sample []float64, min, max float64, n int) []Bin {
+	if len(sample) == 0 || ProfileBins <= 0 {
+		return nil
+	}
+	var (
+		nbins  = ProfileBins
+		bins   []Bin
+		counts = make([]int, nbins)
+		width  = (max - min) / float64(nbins)
+	)
+	if width == 0 || math.IsNaN(width) || math.IsInf(width, 0) {
+		// A single value, or a range too wide for a float64.
+		return []Bin{{min, max, n}}
+	}
+	for _, x := range sample {
+		var i = int((x - min) / width)
+		if i >= nbins {
+			i = nbins - 1
+		} else if i < 0 {
+			i = 0
+		}
+		counts[i]++
+	}
+	var scale = float64(n) / float64(len(sample))
+	for i, c := range counts {
+		bins = append(bins, Bin{
+			Low:   min + float64(i)*width,
+			High:  min + float64(i+1)*width,
+			Count: int(math.Floor(float64(c)*scale + 0.5))})
+	}
+	return bins
+}
+
+//  Write the profile as a table with one line per column. Histograms are
+//  only included in JSON.
+func (p *TableProfile) WriteTable(w io.Writer) error {
+	var tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "column\ttype\tcount\tnulls\tmin\tmax\tmean\tstddev\tlength\tdistinct\ttop\n")
+	for _, c := range p.Columns {
+		var top []string
+		for _, vc := range c.TopValues {
+			top = append(top, fmt.Sprintf("%s(%d)", vc.Value, vc.Count))
+		}
+		var mean, stddev string
+		if c.Type == TypeInteger || c.Type == TypeNumber {
+			mean = strconv.FormatFloat(c.Mean, 'g', 6, 64)
+			stddev = strconv.FormatFloat(c.Stddev, 'g', 6, 64)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%d-%d\t~%d\t%s\n",
+			c.Name, c.Type, c.Count, c.Nulls, c.Min, c.Max, mean, stddev,
+			c.MinLength, c.MaxLength, c.Distinct, strings.Join(top, " "))
+	}
+	return tw.Flush()
+}
+
+//  Write the profile as indented JSON.
+func (p *TableProfile) WriteJSON(w io.Writer) error {
+	var data, err = json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+//  A HyperLogLog sketch estimating the number of distinct strings.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+const hllPrecision = 12
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{make([]uint8, 1<<hllPrecision)}
+}
+
+func (h *hyperLogLog) add(s string) {
+	var f = fnv.New64a()
+	io.WriteString(f, s)
+	var x = f.Sum64()
+	// Mix the bits (the splitmix64 finalizer), as FNV avalanches poorly.
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	var (
+		i    = x >> (64 - hllPrecision)
+		rest = x<<hllPrecision | 1<<(hllPrecision-1)
+		rank = uint8(1)
+	)
+	for rest&(1<<63) == 0 {
+		rank++
+		rest <<= 1
+	}
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() int {
+	var (
+		m     = float64(len(h.registers))
+		sum   float64
+		zeros int
+	)
+	for _, r := range h.registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	var e = 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros)) // Linear counting.
+	}
+	return int(math.Floor(e + 0.5))
+}
+
+//  The Space-Saving algorithm, approximating the most frequent strings
+//  with a bounded number of counters.
+type spaceSaving struct {
+	capacity int
+	counts   map[string]int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity, make(map[string]int)}
+}
+
+func (s *spaceSaving) add(v string) {
+	if _, ok := s.counts[v]; ok || len(s.counts) < s.capacity {
+		s.counts[v]++
+		return
+	}
+	var (
+		minv string
+		minc = -1
+	)
+	for u, c := range s.counts {
+		if minc < 0 || c < minc || c == minc && u < minv {
+			minv, minc = u, c
+		}
+	}
+	delete(s.counts, minv)
+	s.counts[v] = minc + 1
+}
+
+//  The k most frequent strings, ordered by count and then by value.
+func (s *spaceSaving) top(k int) []ValueCount {
+	var vcs []ValueCount
+	for v, c := range s.counts {
+		vcs = append(vcs, ValueCount{v, c})
+	}
+	sort.Slice(vcs, func(i, j int) bool {
+		if vcs[i].Count != vcs[j].Count {
+			return vcs[i].Count > vcs[j].Count
+		}
+		return vcs[i].Value < vcs[j].Value
+	})
+	if len(vcs) > k {
+		vcs = vcs[:k]
+	}
+	return vcs
+}
@@ -0,0 +1,123 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProfile(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NA"
+	var r = StringReader("id,score,name,ok,when\n"+
+		"1,2.5,ann,true,2011-01-02T00:00:00Z\n"+
+		"2,NA,bob,false,2011-03-04T00:00:00Z\n"+
+		"3,4.5,ann,true,2010-12-31T00:00:00Z\n"+
+		"4,,carl\n", config)
+	var p, err = Profile(r)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if p.Rows != 4 || len(p.Columns) != 5 {
+		T.Fatalf("Unexpected profile %+v", p)
+	}
+	var expect = []struct {
+		typ        FieldType
+		count      int
+		nulls      int
+		min, max   string
+		distinct   int
+		top        string
+		minl, maxl int
+	}{
+		{TypeInteger, 4, 0, "1", "4", 4, "1", 1, 1},
+		{TypeNumber, 2, 2, "2.5", "4.5", 2, "2.5", 3, 3},
+		{TypeString, 4, 0, "ann", "carl", 3, "ann", 3, 4},
+		{TypeBoolean, 3, 1, "false", "true", 2, "true", 4, 5},
+		{TypeDatetime, 3, 1, "2010-12-31T00:00:00Z", "2011-03-04T00:00:00Z", 3, "2010-12-31T00:00:00Z", 20, 20},
+	}
+	for i, e := range expect {
+		var c = p.Columns[i]
+		if c.Type != e.typ || c.Count != e.count || c.Nulls != e.nulls ||
+			c.Min != e.min || c.Max != e.max || c.Distinct != e.distinct ||
+			c.TopValues[0].Value != e.top || c.MinLength != e.minl || c.MaxLength != e.maxl {
+			T.Errorf("Unexpected profile of %s: %+v", c.Name, c)
+		}
+	}
+	if c := p.Columns[1]; c.Mean != 3.5 || c.Stddev != 1 {
+		T.Errorf("Unexpected mean %v, stddev %v", c.Mean, c.Stddev)
+	}
+	if h := p.Columns[0].Histogram; len(h) != ProfileBins || h[0].Count != 1 || h[len(h)-1].Count != 1 {
+		T.Errorf("Unexpected histogram %v", h)
+	}
+
+	var buf bytes.Buffer
+	if err = p.WriteJSON(&buf); err != nil {
+		T.Fatal(err)
+	}
+	var decoded TableProfile
+	if err = json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		T.Fatal(err)
+	}
+	if decoded.Columns[4].Type != TypeDatetime {
+		T.Errorf("Unexpected decoded type %v", decoded.Columns[4].Type)
+	}
+	buf.Reset()
+	if err = p.WriteTable(&buf); err != nil {
+		T.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 6 {
+		T.Errorf("Unexpected table\n%s", buf.String())
+	}
+}
+
+func TestProfileApproximate(T *testing.T) {
+	var (
+		config = NewConfig()
+		buf    bytes.Buffer
+	)
+	for i := 0; i < 20000; i++ {
+		buf.WriteString(strconv.Itoa(i%5000) + "," + strconv.Itoa(i%7) + "\n")
+	}
+	var p, err = Profile(NewReader(&buf, config))
+	if err != nil {
+		T.Fatal(err)
+	}
+	if d := p.Columns[0].Distinct; d < 4750 || d > 5250 {
+		T.Errorf("Distinct estimate %d too far from 5000", d)
+	}
+	if top := p.Columns[1].TopValues; len(top) != ProfileTopK || top[0].Value != "0" || top[0].Count != 2858 {
+		T.Errorf("Unexpected top values %v", top)
+	}
+	var total int
+	for _, b := range p.Columns[0].Histogram {
+		total += b.Count
+	}
+	if total < 19900 || total > 20100 {
+		T.Errorf("Histogram counts sum to %d", total)
+	}
+}
+
+func TestProfileNonFinite(T *testing.T) {
+	var p, err = Profile(StringReader("1,1\nNaN,-1e308\n3,1e308\n+Inf,2\ninf,3\n", NewConfig()))
+	if err != nil {
+		T.Fatal(err)
+	}
+	if c := p.Columns[0]; c.Type != TypeString || c.Histogram != nil {
+		T.Errorf("Unexpected profile of non-finite numbers: %+v", c)
+	}
+	if h := p.Columns[1].Histogram; p.Columns[1].Type != TypeNumber || len(h) != 1 || h[0].Count != 5 {
+		T.Errorf("Unexpected histogram of a huge range %v", h)
+	}
+	var buf bytes.Buffer
+	if err = p.WriteJSON(&buf); err != nil {
+		T.Fatal(err)
+	}
+}
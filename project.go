@@ -0,0 +1,220 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: project.go
+*  Description: Reading a projection of the columns of CSV data.
+ */
+import (
+	"strconv"
+	"strings"
+)
+
+//  A wrapper around a Reader that yields only selected columns, in a
+//  selected order. To avoid allocation, the Fields of each Row returned by
+//  ReadRow (and passed by Do and DoN) share a buffer that is overwritten
+//  by the next read. Copy them to keep them.
+type ProjectedReader struct {
+	r       *Reader
+	spec    []string
+	indices []int
+	header  Header
+	fields  []string
+	ready   bool
+}
+
+//  Project the columns of a Reader. Each item of the spec selects columns
+//  by name, by index (starting at 1), or by an inclusive range of indices
+//  like "2-5" or "3-" (through the last column). Items starting with '!'
+//  remove columns from the selection instead. When the first item removes
+//  columns, the selection starts with all of them. For example,
+//
+//      NewProjectedReader(csvr, "3", "1", "name")
+//      NewProjectedReader(csvr, "!secret")
+//      NewProjectedReader(csvr, "2-5", "!4")
+//
+//  Names take precedence over indices when a header has a column named
+//  like an index. Without a header, only indices can be used, and the
+//  number of columns is taken from the first row. Missing fields are
+//  replaced by the Reader's Null.
+func NewProjectedReader(r *Reader, spec ...string) (*ProjectedReader, error) {
+	var p = &ProjectedReader{r: r, spec: spec}
+	var header, err = r.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		if err = p.resolve(header, len(header)); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+//  Resolve the spec to column indices.
+func (p *ProjectedReader) resolve(header Header, width int) error {
+	var (
+		indices []int
+		err     error
+	)
+	for i, item := range p.spec {
+		var exclude = strings.HasPrefix(item, "!")
+		if exclude {
+			item = item[1:]
+		}
+		if i == 0 && exclude {
+			for j := 0; j < width; j++ {
+				indices = append(indices, j)
+			}
+		}
+		var selected []int
+		if selected, err = selectColumns(header, width, item); err != nil {
+			return err
+		}
+		if !exclude {
+			indices = append(indices, selected...)
+			continue
+		}
+		var kept = indices[:0]
+		for _, j := range indices {
+			if !containsInt(selected, j) {
+				kept = append(kept, j)
+			}
+		}
+		indices = kept
+	}
+	p.indices = indices
+	p.fields = make([]string, len(indices))
+	if header != nil {
+		p.header = make(Header, len(indices))
+		for i, j := range indices {
+			p.header[i] = header[j]
+		}
+	}
+	p.ready = true
+	return nil
+}
+
+//  The indices of the columns selected by one item of a projection spec.
+func selectColumns(header Header, width int, item string) ([]int, error) {
+	if i := header.Index(item); i >= 0 {
+		return []int{i}, nil
+	}
+	var (
+		lo, hi = item, item
+		dash   = strings.Index(item, "-")
+	)
+	if dash >= 0 {
+		lo, hi = item[:dash], item[dash+1:]
+	}
+	var (
+		first, errlo = strconv.Atoi(lo)
+		last, errhi  = strconv.Atoi(hi)
+	)
+	if dash >= 0 && hi == "" {
+		last, errhi = width, nil
+	}
+	if errlo != nil || errhi != nil || first < 1 || last > width || first > last {
+		return nil, &ColumnError{item}
+	}
+	var indices []int
+	for i := first - 1; i < last; i++ {
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+//  Like Reader.LineNum.
+func (p *ProjectedReader) LineNum() int {
+	return p.r.LineNum()
+}
+
+//  Like Reader.LeadingComments.
+func (p *ProjectedReader) LeadingComments() []string {
+	return p.r.LeadingComments()
+}
+
+//  Returns the projected header, or nil if the Reader has no header.
+func (p *ProjectedReader) Header() (Header, error) {
+	return p.header, nil
+}
+
+//  Read a row and project its fields. The returned Fields are only valid
+//  until the next read.
+func (p *ProjectedReader) ReadRow() Row {
+	var r = p.r.ReadRow()
+	if r.HasError() {
+		return r
+	}
+	if !p.ready {
+		if err := p.resolve(nil, len(r.Fields)); err != nil {
+			r.Error = err
+			return r
+		}
+	}
+	for i, j := range p.indices {
+		p.fields[i] = fieldOr(r.Fields, j, p.r.Null)
+	}
+	r.Fields = p.fields
+	r.Header = p.header
+	return r
+}
+
+//  Like Reader.Do, for projected rows.
+func (p *ProjectedReader) Do(f func(Row) bool) {
+	for r := p.ReadRow(); !r.HasEOF(); r = p.ReadRow() {
+		if !f(r) {
+			break
+		}
+	}
+}
+
+//  Like Reader.DoN, for projected rows.
+func (p *ProjectedReader) DoN(n int, f func(Row) bool) {
+	for i := 0; i < n; i++ {
+		var r = p.ReadRow()
+		if r.HasEOF() || !f(r) {
+			break
+		}
+	}
+}
+
+//  Read projected rows into a preallocated buffer. Returns the number of
+//  rows read, and any error encountered. Unlike those returned by ReadRow,
+//  these rows do not share memory.
+func (p *ProjectedReader) ReadRows(rbuf [][]string) (int, error) {
+	var (
+		i   int
+		err error
+	)
+	p.DoN(len(rbuf), func(r Row) bool {
+		if err = r.Error; err != nil {
+			return false
+		}
+		rbuf[i] = append([]string(nil), r.Fields...)
+		i++
+		return true
+	})
+	return i, err
+}
+
+//  Reads the remaining projected rows. Unlike those returned by ReadRow,
+//  these rows do not share memory.
+func (p *ProjectedReader) RemainingRows() ([][]string, error) {
+	return p.RemainingRowsSize(16)
+}
+
+//  Like RemainingRows, with an initial capacity for the rows returned.
+func (p *ProjectedReader) RemainingRowsSize(size int) ([][]string, error) {
+	var rows = make([][]string, 0, size)
+	for r := p.ReadRow(); !r.HasEOF(); r = p.ReadRow() {
+		if r.HasError() {
+			return rows, r.Error
+		}
+		rows = append(rows, append([]string(nil), r.Fields...))
+	}
+	return rows, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+func TestProjectedReader(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var tests = []struct {
+		spec   []string
+		header []string
+		rows   [][]string
+	}{
+		{[]string{"3", "1", "name"},
+			[]string{"c", "a", "name"},
+			[][]string{{"3", "1", "x"}, {"", "4", "y"}}},
+		{[]string{"!secret"},
+			[]string{"a", "b", "c", "name"},
+			[][]string{{"1", "2", "3", "x"}, {"4", "5", "", "y"}}},
+		{[]string{"2-", "!c"},
+			[]string{"b", "secret", "name"},
+			[][]string{{"2", "s", "x"}, {"5", "t", "y"}}},
+	}
+	for _, test := range tests {
+		var r = StringReader("a,b,c,secret,name\n1,2,3,s,x\n4,5,,t,y\n", config)
+		var p, err = NewProjectedReader(r, test.spec...)
+		if err != nil {
+			T.Errorf("%v: %v", test.spec, err)
+			continue
+		}
+		var header, _ = p.Header()
+		if !equalStrings(header, test.header) {
+			T.Errorf("%v: header %v (!= %v)", test.spec, header, test.header)
+		}
+		var rows, rerr = p.RemainingRows()
+		if rerr != nil {
+			T.Errorf("%v: %v", test.spec, rerr)
+		}
+		if len(rows) != len(test.rows) {
+			T.Errorf("%v: rows %v (!= %v)", test.spec, rows, test.rows)
+			continue
+		}
+		for i := range rows {
+			if !equalStrings(rows[i], test.rows[i]) {
+				T.Errorf("%v: row %v (!= %v)", test.spec, rows[i], test.rows[i])
+			}
+		}
+	}
+}
+
+func TestProjectedReaderNoHeader(T *testing.T) {
+	var r = StringReader("1,2,3\n4\n", nil)
+	var p, err = NewProjectedReader(r, "!1")
+	if err != nil {
+		T.Fatal(err)
+	}
+	var rows, _ = p.RemainingRows()
+	if len(rows) != 2 || !equalStrings(rows[0], []string{"2", "3"}) ||
+		!equalStrings(rows[1], []string{"", ""}) {
+		T.Errorf("Unexpected rows %v", rows)
+	}
+}
+
+func TestProjectedReaderErrors(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	for _, spec := range []string{"d", "0", "3-2", "1-9", "!x"} {
+		var r = StringReader("a,b,c\n1,2,3\n", config)
+		if _, err := NewProjectedReader(r, spec); err == nil {
+			T.Errorf("No error for spec %q", spec)
+		}
+	}
+}
+
+func TestProjectedReaderReadRows(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var r = StringReader("a,b,c\n1,2,3\n4,5,6\n7,8,9\n", config)
+	var p, err = NewProjectedReader(r, "c", "a")
+	if err != nil {
+		T.Fatal(err)
+	}
+	var rows = make([][]string, 2)
+	var n int
+	if n, err = p.ReadRows(rows); err != nil || n != 2 {
+		T.Fatalf("Read %d rows, %v", n, err)
+	}
+	if !equalStrings(rows[0], []string{"3", "1"}) || !equalStrings(rows[1], []string{"6", "4"}) {
+		T.Errorf("Unexpected rows %v", rows)
+	}
+	var last [][]string
+	p.DoN(5, func(r Row) bool {
+		last = append(last, append([]string(nil), r.Fields...))
+		return true
+	})
+	if len(last) != 1 || !equalStrings(last[0], []string{"9", "7"}) || p.LineNum() != 4 {
+		T.Errorf("Unexpected rows %v (line %d)", last, p.LineNum())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 //  readerBufferMinimumSize is the smallest csvutil will allow the
@@ -23,6 +24,9 @@ type Reader struct {
 	pi         int           // An index into the p buffer.
 	lineNum    int
 	pastHeader bool
+	header     Header   // Column names, when HasHeader is true.
+	comments   []string // Comments preceding the first row.
+	decoding   *decodeCache
 }
 
 //  Create a new reader object.
@@ -81,6 +85,7 @@ func (csvr *Reader) readLine() (string, error) {
 			}
 			var p = make([]byte, pLen)
 			copy(p, csvr.p[:csvr.pi])
+			csvr.p = p
 		}
 		csvr.pi += copy(csvr.p[csvr.pi:], piece)
 	}
@@ -97,10 +102,42 @@ func (csvr *Reader) LineNum() int {
 	return csvr.lineNum
 }
 
+//  Returns the comments read before the first row (or header) of input,
+//  without their comment prefixes.
+func (csvr *Reader) LeadingComments() []string {
+	return csvr.comments
+}
+
+//  Returns the header of the input, reading it first if necessary. When
+//  the Reader's Config does not have HasHeader set, the returned Header is
+//  nil.
+func (csvr *Reader) Header() (Header, error) {
+	if !csvr.HasHeader || csvr.header != nil {
+		return csvr.header, nil
+	}
+	var r = csvr.readRow()
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	csvr.header = Header(r.Fields)
+	return csvr.header, nil
+}
+
 //  Attempt to read up to a new line, skipping any comment lines found in
 //  the process. Return a Row object containing the fields read and any
-//  error encountered.
+//  error encountered. If the Reader's Config has HasHeader set, the header
+//  is not returned as a Row but is referenced by each Row read.
 func (csvr *Reader) ReadRow() Row {
+	var header, err = csvr.Header()
+	if err != nil {
+		return Row{Error: err}
+	}
+	var r = csvr.readRow()
+	r.Header = header
+	return r
+}
+
+func (csvr *Reader) readRow() Row {
 	var (
 		r    Row
 		line string
@@ -118,11 +155,15 @@ func (csvr *Reader) ReadRow() Row {
 		} else if csvr.pastHeader && !csvr.CommentsInBody {
 			break
 		}
+		if !csvr.pastHeader {
+			csvr.comments = append(csvr.comments, line[len(csvr.CommentPrefix):])
+		}
 	}
 	csvr.pastHeader = true
+	r.Line = csvr.lineNum
 
 	// Break the line up into fields.
-	r.Fields = strings.FieldsFunc(line, func(c rune) bool { return csvr.IsSep(c) })
+	r.Fields = csvr.splitFields(line)
 
 	// Trim any unwanted characters.
 	if csvr.Trim {
@@ -133,6 +174,25 @@ func (csvr *Reader) ReadRow() Row {
 	return r
 }
 
+//  Split a line at each separator. Empty fields are kept so that columns
+//  stay aligned, but an empty line has no fields.
+func (csvr *Reader) splitFields(line string) []string {
+	var (
+		fields = make([]string, 0, 8)
+		start  int
+	)
+	if len(line) == 0 {
+		return fields
+	}
+	for i, c := range line {
+		if csvr.IsSep(c) {
+			fields = append(fields, line[start:i])
+			start = i + utf8.RuneLen(c)
+		}
+	}
+	return append(fields, line[start:])
+}
+
 //  Read rows into a preallocated buffer. Return the number of rows read,
 //  and any error encountered.
 func (csvr *Reader) ReadRows(rbuf [][]string) (int, error) {
@@ -5,6 +5,7 @@
 package csvutil
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -25,6 +26,26 @@ func TestDo(T *testing.T) {
 	})
 }
 
+//  Empty fields are kept, so "a,,b" has three fields, not two.
+func TestReadRowEmptyFields(T *testing.T) {
+	var csvr = StringReader("a,,b\n,x,\n", nil)
+	var expect = [][]string{{"a", "", "b"}, {"", "x", ""}}
+	for i, fields := range expect {
+		var row = csvr.ReadRow()
+		if row.Error != nil {
+			T.Fatal(row.Error)
+		}
+		if len(row.Fields) != len(fields) {
+			T.Fatalf("Row %d: unexpected fields %q (!= %q)", i, row.Fields, fields)
+		}
+		for j := range fields {
+			if row.Fields[j] != fields[j] {
+				T.Errorf("Row %d: unexpected fields %q (!= %q)", i, row.Fields, fields)
+			}
+		}
+	}
+}
+
 // TEST1 - Simple 3x3 matrix w/ comma separators and w/o excess whitespace.
 func TestReadRow(T *testing.T) {
 	T.Log("Beginning test\n")
@@ -104,3 +125,40 @@ func TestComments(T *testing.T) {
 		}
 	}
 }
+
+func TestHeader(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		reader      = StringReader("name,,age\nalice,,30\n", config)
+		header, err = reader.Header()
+	)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(header) != 3 || header.Index("age") != 2 {
+		T.Errorf("Unexpected header %q", header)
+	}
+	var row = reader.ReadRow()
+	if len(row.Fields) != 3 || row.Fields[1] != "" {
+		T.Errorf("Empty field not preserved %q", row.Fields)
+	}
+	if age, ok := row.Get("age"); !ok || age != "30" {
+		T.Errorf("Unexpected age %q", age)
+	}
+	if _, ok := row.Get("height"); ok {
+		T.Error("Found a nonexistent column")
+	}
+}
+
+func TestReadLongLine(T *testing.T) {
+	var long = strings.Repeat("x", 10000)
+	var reader = NewReaderSize(strings.NewReader("a,"+long+"\nb,c\n"), nil, 16)
+	var rows, err = reader.RemainingRows()
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[0]) != 2 || rows[0][1] != long {
+		T.Errorf("Long line not read intact")
+	}
+}
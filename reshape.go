@@ -0,0 +1,166 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: reshape.go
+*  Description: Converting between wide and long layouts of CSV data.
+ */
+import (
+	"strings"
+)
+
+//  Unpivot the rows of r from a wide layout to a long one. Each row read
+//  is written as one row per value column, holding the id columns, the
+//  name of the value column (in a column named varName) and its field (in
+//  a column named valueName). When no value columns are given, all the
+//  columns that are not id columns are used. The Reader must have a
+//  header. Rows are written as they are read.
+func Melt(w *Writer, r *Reader, idColumns, valueColumns []string, varName, valueName string) error {
+	var header, err = r.Header()
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return ErrorNoHeader
+	}
+	if len(valueColumns) == 0 {
+		for _, name := range header {
+			if !containsString(idColumns, name) {
+				valueColumns = append(valueColumns, name)
+			}
+		}
+	}
+	var ids, vals []int
+	if ids, err = header.Indices(idColumns...); err != nil {
+		return err
+	}
+	if vals, err = header.Indices(valueColumns...); err != nil {
+		return err
+	}
+	var out = append(append([]string(nil), idColumns...), varName, valueName)
+	if _, err = w.WriteRow(out...); err != nil {
+		return err
+	}
+	r.Do(func(row Row) bool {
+		if err = row.Error; err != nil {
+			return false
+		}
+		for i, j := range ids {
+			out[i] = fieldOr(row.Fields, j, w.Null)
+		}
+		for i, j := range vals {
+			out[len(ids)] = valueColumns[i]
+			out[len(ids)+1] = fieldOr(row.Fields, j, w.Null)
+			if _, err = w.WriteRow(out...); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+//  Pivot the rows of r from a long layout to a wide one. Rows are grouped
+//  by the index columns, and each distinct field of the columns column
+//  becomes an output column, in the order the fields first appear. Each
+//  cell holds the aggregate of the values column for the rows of its
+//  group and column. The values column may be empty only when aggregating
+//  with Count (otherwise ErrorNoAggColumn is returned). Cells without rows
+//  hold w's Null. The Reader must have a header.
+//
+//  Nothing is written until all input is read. Memory is proportional to
+//  the number of groups times the number of distinct output columns (plus
+//  the distinct values of each cell when aggregating with CountDistinct),
+//  not to the number of rows.
+func Pivot(w *Writer, r *Reader, index []string, columns, values string, aggregate AggFunc) error {
+	var header, err = r.Header()
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return ErrorNoHeader
+	}
+	if values == "" && aggregate != Count {
+		return ErrorNoAggColumn
+	}
+	var (
+		keys []int
+		col  []int
+		aggs = []Agg{{Func: aggregate, Column: values}}
+		vcol = []int{-1}
+	)
+	if keys, err = header.Indices(index...); err != nil {
+		return err
+	}
+	if col, err = header.Indices(columns); err != nil {
+		return err
+	}
+	if values != "" {
+		if vcol, err = header.Indices(values); err != nil {
+			return err
+		}
+	}
+	var (
+		groups  = make(map[string]*pivotGroup)
+		order   []*pivotGroup
+		names   []string
+		known   = make(map[string]bool)
+		keyVals = make([]string, len(keys))
+	)
+	r.Do(func(row Row) bool {
+		if err = row.Error; err != nil {
+			return false
+		}
+		for i, k := range keys {
+			keyVals[i] = fieldOr(row.Fields, k, r.Null)
+		}
+		var name = fieldOr(row.Fields, col[0], r.Null)
+		if !known[name] {
+			known[name] = true
+			names = append(names, name)
+		}
+		var hkey = strings.Join(keyVals, "\x00")
+		var g = groups[hkey]
+		if g == nil {
+			g = &pivotGroup{append([]string(nil), keyVals...), make(map[string]*aggGroup)}
+			groups[hkey] = g
+			order = append(order, g)
+		}
+		var cell = g.cells[name]
+		if cell == nil {
+			cell = newAggGroup(nil, aggs, w.Null)
+			g.cells[name] = cell
+		}
+		err = cell.add(r.Config, row, header, aggs, vcol)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = w.WriteRow(append(append([]string(nil), index...), names...)...); err != nil {
+		return err
+	}
+	var out = make([]string, len(keys)+len(names))
+	for _, g := range order {
+		copy(out, g.key)
+		for i, name := range names {
+			out[len(keys)+i] = w.Null
+			if cell := g.cells[name]; cell != nil {
+				out[len(keys)+i] = cell.fields(aggs)[0]
+			}
+		}
+		if _, err = w.WriteRow(out...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//  A row of a pivot table.
+type pivotGroup struct {
+	key   []string
+	cells map[string]*aggGroup
+}
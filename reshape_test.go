@@ -0,0 +1,60 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+func TestMelt(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var (
+		r    = StringReader("id,jan,feb\na,1,2\nb,3\n", config)
+		w, b = BufferWriter(config)
+	)
+	if err := Melt(w, r, []string{"id"}, nil, "month", "value"); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	var expect = "id,month,value\na,jan,1\na,feb,2\nb,jan,3\nb,feb,\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+}
+
+func TestPivot(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NA"
+	var (
+		r    = StringReader("id,month,value\na,jan,1\na,feb,2\nb,jan,3\na,jan,4\n", config)
+		w, b = BufferWriter(config)
+	)
+	if err := Pivot(w, r, []string{"id"}, "month", "value", Sum); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	var expect = "id,jan,feb\na,5,2\nb,3,NA\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+
+	r = StringReader("id,month\na,jan\na,jan\nb,feb\n", config)
+	w, b = BufferWriter(config)
+	if err := Pivot(w, r, []string{"id"}, "month", "", Count); err != nil {
+		T.Fatal(err)
+	}
+	w.Flush()
+	expect = "id,jan,feb\na,2,NA\nb,NA,1\n"
+	if out := b.String(); out != expect {
+		T.Errorf("Unexpected output\n%s\n(!=\n%s)", out, expect)
+	}
+	r = StringReader("id,month\na,jan\n", config)
+	w, b = BufferWriter(config)
+	if err := Pivot(w, r, []string{"id"}, "month", "", Sum); err != ErrorNoAggColumn {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
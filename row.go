@@ -11,12 +11,17 @@ package csvutil
 *  Description: Row related types and methods.
  */
 import (
+	"encoding"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	//"fmt"
 	//"log"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // See strconv.Ftoa32()
@@ -31,6 +36,8 @@ var (
 type Row struct {
 	Fields []string "CSV row field data"
 	Error  error    "Error encountered reading"
+	Header Header   // Column names of the data, when known.
+	Line   int      // Line number of the row in its input, when known.
 }
 
 //  A wrapper for the test r.Error == os.EOF
@@ -52,6 +59,20 @@ var (
 	ErrorCantSet       = errors.New("Cannot set value.")
 )
 
+//  An error tied to a line of CSV input, and possibly a column.
+type LineError struct {
+	Line   int    // Line number of the input.
+	Column string // Column name, if known.
+	Err    error  // The underlying error.
+}
+
+func (e *LineError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("line %d, column %q: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
 func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 	if i >= len(r.Fields) {
 		return 0, ErrorIndex
@@ -59,6 +80,12 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 	if !x.CanSet() {
 		return 0, ErrorCantSet
 	}
+	if u := textUnmarshaler(x); u != nil {
+		if err := u.UnmarshalText([]byte(r.Fields[i])); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
 	var (
 		assigned int
 		errc     error
@@ -79,7 +106,7 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 		fallthrough
 	case reflect.Int64:
 		var vint int64
-		vint, errc = strconv.ParseInt(r.Fields[i], 10, 64)
+		vint, errc = strconv.ParseInt(r.Fields[i], 10, x.Type().Bits())
 		if errc == nil {
 			x.SetInt(vint)
 			assigned++
@@ -94,7 +121,7 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 		fallthrough
 	case reflect.Uint64:
 		var vuint uint64
-		vuint, errc = strconv.ParseUint(r.Fields[i], 10, 64)
+		vuint, errc = strconv.ParseUint(r.Fields[i], 10, x.Type().Bits())
 		if errc == nil {
 			x.SetUint(vuint)
 			assigned++
@@ -103,7 +130,7 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 		fallthrough
 	case reflect.Float64:
 		var vfloat float64
-		vfloat, errc = strconv.ParseFloat(r.Fields[i], 64)
+		vfloat, errc = strconv.ParseFloat(r.Fields[i], x.Type().Bits())
 		if errc == nil {
 			x.SetFloat(vfloat)
 			assigned++
@@ -111,7 +138,12 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 	case reflect.Complex64:
 		fallthrough
 	case reflect.Complex128:
-		errc = ErrorUnimplemented
+		var vcomplex complex128
+		vcomplex, errc = strconv.ParseComplex(r.Fields[i], x.Type().Bits())
+		if errc == nil {
+			x.SetComplex(vcomplex)
+			assigned++
+		}
 	case reflect.Bool:
 		var vbool bool
 		vbool, errc = strconv.ParseBool(r.Fields[i])
@@ -126,7 +158,6 @@ func (r Row) formatReflectValue(i int, x reflect.Value) (int, error) {
 }
 
 func (r Row) formatValue(i int, x interface{}) (int, error) {
-	// TODO add complex types
 	if i >= len(r.Fields) {
 		return 0, ErrorIndex
 	}
@@ -162,7 +193,7 @@ func (r Row) formatValue(i int, x interface{}) (int, error) {
 		return assigned, errc
 	case reflect.Map:
 		//log.Print("MapType")
-		return 0, ErrorUnimplemented
+		return r.formatMap(i, value)
 	default:
 		return 0, ErrorFieldType
 	}
@@ -171,6 +202,9 @@ func (r Row) formatValue(i int, x interface{}) (int, error) {
 		eType = eVal.Type()
 		eKind = eType.Kind()
 	)
+	if u := textUnmarshaler(eVal); u != nil {
+		return r.formatReflectValue(i, eVal)
+	}
 	switch eKind {
 	// Format pointers to standard types.
 	case reflect.Struct:
@@ -178,8 +212,20 @@ func (r Row) formatValue(i int, x interface{}) (int, error) {
 		case reflect.Ptr:
 			n = eVal.NumField()
 			for j := 0; j < n; j++ {
-				var vj = eVal.Field(j)
-				rvasgn, rverr := r.formatReflectValue(i+j, vj)
+				var (
+					vj     = eVal.Field(j)
+					rvasgn int
+					rverr  error
+				)
+				if complexParts(eType.Field(j)) {
+					rvasgn, rverr = r.formatComplexParts(i+assigned, vj)
+				} else if def, ok := tagDefault(eType.Field(j)); ok && fieldOr(r.Fields, i+assigned, "") == "" {
+					if _, rverr = (Row{Fields: []string{def}}).formatReflectValue(0, vj); i+assigned < len(r.Fields) {
+						rvasgn = 1
+					}
+				} else {
+					rvasgn, rverr = r.formatReflectValue(i+assigned, vj)
+				}
 				assigned += rvasgn
 				if rverr != nil {
 					return assigned, rverr
@@ -205,16 +251,113 @@ func (r Row) formatValue(i int, x interface{}) (int, error) {
 		return assigned, errc
 	case reflect.Map:
 		//log.Print("MapType")
-		return 0, ErrorUnimplemented
+		return r.formatMap(i, eVal)
 	default:
 		assigned, errc = r.formatReflectValue(i, eVal)
 	}
 	return assigned, errc
 }
 
+//  Assign the fields at index i and i+1 to the real and imaginary parts of
+//  a complex number.
+func (r Row) formatComplexParts(i int, x reflect.Value) (int, error) {
+	if i+1 >= len(r.Fields) {
+		return 0, ErrorIndex
+	}
+	if !x.CanSet() {
+		return 0, ErrorCantSet
+	}
+	var bits = x.Type().Bits() / 2
+	var re, err = strconv.ParseFloat(r.Fields[i], bits)
+	if err != nil {
+		return 0, err
+	}
+	im, err := strconv.ParseFloat(r.Fields[i+1], bits)
+	if err != nil {
+		return 1, err
+	}
+	x.SetComplex(complex(re, im))
+	return 2, nil
+}
+
+//  Whether a struct field is a complex number tagged with the parts option,
+//  to be formatted as two fields, its real and imaginary parts (see
+//  Row.Decode).
+func complexParts(sf reflect.StructField) bool {
+	if k := sf.Type.Kind(); k != reflect.Complex64 && k != reflect.Complex128 {
+		return false
+	}
+	for _, opt := range strings.Split(sf.Tag.Get("csv"), ",")[1:] {
+		if opt == "parts" || strings.HasPrefix(opt, "parts=") {
+			return true
+		}
+	}
+	return false
+}
+
+//  The default option of a struct field's `csv` tag (see Row.Decode).
+func tagDefault(sf reflect.StructField) (string, bool) {
+	for _, opt := range strings.Split(sf.Tag.Get("csv"), ",")[1:] {
+		if strings.HasPrefix(opt, "default=") {
+			return opt[len("default="):], true
+		}
+	}
+	return "", false
+}
+
+//  Format a struct's fields, as two fields for complex numbers tagged with
+//  the parts option.
+func formatStructFields(s reflect.Value) ([]string, error) {
+	var formatted = make([]string, 0, s.NumField())
+	for j := 0; j < s.NumField(); j++ {
+		var vj = s.Field(j)
+		if complexParts(s.Type().Field(j)) {
+			var (
+				c    = vj.Complex()
+				bits = vj.Type().Bits() / 2
+			)
+			formatted = append(formatted,
+				strconv.FormatFloat(real(c), FloatFmt, FloatPrec, bits),
+				strconv.FormatFloat(imag(c), FloatFmt, FloatPrec, bits))
+			continue
+		}
+		var field, err = formatReflectValue(vj)
+		if err != nil {
+			return formatted, err
+		}
+		formatted = append(formatted, field)
+	}
+	return formatted, nil
+}
+
+//  Assign the fields from index i on to a map, keyed by their Header
+//  columns (see Row.Decode).
+func (r Row) formatMap(i int, m reflect.Value) (int, error) {
+	if r.Header == nil {
+		return 0, ErrorNoHeader
+	}
+	if i > len(r.Header) {
+		return 0, ErrorIndex
+	}
+	var rest = Row{Header: r.Header[i:], Fields: r.Fields[i:], Line: r.Line}
+	if err := decodeMap(DefaultConfig, rest, m, nil); err != nil {
+		return 0, err
+	}
+	return len(rest.Fields), nil
+}
+
 //  Iteratively take values from the argument list and assigns to them
 //  successive fields from the row object. Returns the number of row fields
 //  assigned to arguments and any error that occurred.
+//
+//  Struct fields with a default option in their `csv` tag (see Row.Decode)
+//  are given the default when their field is empty or missing, rather than
+//  failing with ErrorIndex.
+//
+//  Values of *big.Int, *big.Float, *big.Rat and Decimal (or pointers to
+//  them) are parsed with their UnmarshalText methods, and FormatRow formats
+//  them with MarshalText. Other types with these methods are formatted by
+//  kind, as before.
 func (r Row) Format(x ...interface{}) (int, error) {
 	var (
 		assigned int
@@ -237,53 +380,132 @@ func formatReflectValue(x reflect.Value) (string, error) {
 	       return "", ErrorCantSet
 	   }
 	*/
+	if text, ok, err := formatText(x); ok {
+		return text, err
+	}
 	var (
 		errc error
 		kind = x.Kind()
 		//vintstr   string
 	)
 	switch kind {
-	// Format pointers to standard types.
+	// Format standard kinds, including named types like `type Status string`.
 	case reflect.String:
-		return x.Interface().(string), nil
-	case reflect.Int:
-		return strconv.Itoa(x.Interface().(int)), nil
-	case reflect.Int8:
-		return strconv.FormatInt(int64(x.Interface().(int8)), 10), nil
-	case reflect.Int16:
-		return strconv.FormatInt(int64(x.Interface().(int16)), 10), nil
-	case reflect.Int32:
-		return strconv.FormatInt(int64(x.Interface().(int32)), 10), nil
-	case reflect.Int64:
-		return strconv.FormatInt(x.Interface().(int64), 10), nil
-	case reflect.Uint:
-		return strconv.FormatUint(uint64(x.Interface().(uint)), 10), nil
-	case reflect.Uint8:
-		return strconv.FormatUint(uint64(x.Interface().(uint8)), 10), nil
-	case reflect.Uint16:
-		return strconv.FormatUint(uint64(x.Interface().(uint16)), 10), nil
-	case reflect.Uint32:
-		return strconv.FormatUint(uint64(x.Interface().(uint32)), 10), nil
-	case reflect.Uint64:
-		return strconv.FormatUint(x.Interface().(uint64), 10), nil
-	case reflect.Float32:
-		return strconv.FormatFloat(float64(x.Interface().(float32)), FloatFmt, FloatPrec, 32), nil
-	case reflect.Float64:
-		return strconv.FormatFloat(x.Interface().(float64), FloatFmt, FloatPrec, 64), nil
+		return x.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(x.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(x.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(x.Float(), FloatFmt, FloatPrec, x.Type().Bits()), nil
 	case reflect.Complex64:
 		fallthrough
 	case reflect.Complex128:
-		errc = ErrorUnimplemented
+		return strconv.FormatComplex(x.Complex(), FloatFmt, FloatPrec, x.Type().Bits()), nil
 	case reflect.Bool:
-		return strconv.FormatBool(x.Interface().(bool)), nil
+		return strconv.FormatBool(x.Bool()), nil
+	case reflect.Interface:
+		if x.IsNil() {
+			return "", nil
+		}
+		return formatReflectValue(x.Elem())
 	default:
 		errc = ErrorFieldType
 	}
 	return "", errc
 }
 
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	bigIntType          = reflect.TypeOf(big.Int{})
+	bigFloatType        = reflect.TypeOf(big.Float{})
+	bigRatType          = reflect.TypeOf(big.Rat{})
+	decimalType         = reflect.TypeOf(Decimal{})
+)
+
+//  Whether values of type t (or pointers to them) are parsed and formatted
+//  as text: big.Int, big.Float, big.Rat and Decimal. Other types with text
+//  methods, like time.Time (which has Config.TimeLayout), keep their
+//  formatting by kind.
+func isTextType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case bigIntType, bigFloatType, bigRatType, decimalType:
+		return true
+	}
+	return false
+}
+
+//  Returns x (a settable value), or a pointer to it, as an
+//  encoding.TextUnmarshaler, allocating nil pointers. Returns nil when x
+//  is not a text type (see isTextType). A *big.Float without a precision
+//  is given BigFloatPrec, so fields don't get rounded to 64 bits.
+func textUnmarshaler(x reflect.Value) encoding.TextUnmarshaler {
+	if x.Kind() == reflect.Interface || !isTextType(x.Type()) {
+		return nil
+	}
+	switch {
+	case x.Kind() == reflect.Ptr && x.Type().Implements(textUnmarshalerType):
+		if x.IsNil() {
+			if !x.CanSet() {
+				return nil
+			}
+			x.Set(reflect.New(x.Type().Elem()))
+		}
+	case x.Kind() != reflect.Interface && x.CanAddr() && reflect.PtrTo(x.Type()).Implements(textUnmarshalerType):
+		x = x.Addr()
+	default:
+		return nil
+	}
+	if f, ok := x.Interface().(*big.Float); ok && f.Prec() == 0 {
+		f.SetPrec(BigFloatPrec)
+	}
+	return x.Interface().(encoding.TextUnmarshaler)
+}
+
+//  Format x with the MarshalText method of x, or of a pointer to it. Nil
+//  pointers are formatted as "", and a *big.Float is formatted with FloatFmt
+//  and FloatPrec, like other floats. Returns false when x is not a text
+//  type (see isTextType).
+func formatText(x reflect.Value) (string, bool, error) {
+	if x.Kind() == reflect.Interface || !isTextType(x.Type()) {
+		return "", false, nil
+	}
+	if !x.Type().Implements(textMarshalerType) {
+		if !reflect.PtrTo(x.Type()).Implements(textMarshalerType) {
+			return "", false, nil
+		}
+		var p = reflect.New(x.Type())
+		p.Elem().Set(x)
+		x = p
+	}
+	if x.Kind() == reflect.Ptr && x.IsNil() {
+		return "", true, nil
+	}
+	if f, ok := x.Interface().(*big.Float); ok {
+		return f.Text(FloatFmt, FloatPrec), true, nil
+	}
+	var text, err = x.Interface().(encoding.TextMarshaler).MarshalText()
+	return string(text), true, err
+}
+
+//  The values of a map, in the sorted order of its keys.
+func sortedMapValues(m reflect.Value) []reflect.Value {
+	var keys = m.MapKeys()
+	sort.Slice(keys, func(a, b int) bool {
+		return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+	})
+	var values = make([]reflect.Value, len(keys))
+	for j, k := range keys {
+		values[j] = m.MapIndex(k)
+	}
+	return values
+}
+
 func formatValue(x interface{}) ([]string, error) {
-	// TODO add complex types
 	var (
 		formatted    = make([]string, 0, 1)
 		appendwhenok = func(s string, e error) error {
@@ -299,6 +521,10 @@ func formatValue(x interface{}) ([]string, error) {
 	if !value.IsValid() {
 		return formatted, ErrorFieldType
 	}
+	if text, ok, err := formatText(value); ok {
+		errc = appendwhenok(text, err)
+		return formatted, errc
+	}
 	//var t = value.Type()
 	var kind = value.Kind()
 	switch kind {
@@ -306,15 +532,7 @@ func formatValue(x interface{}) ([]string, error) {
 		//log.Print("PtrType")
 		break
 	case reflect.Struct:
-		n = value.NumField()
-		for j := 0; j < n; j++ {
-			var vj = value.Field(j)
-			errc = appendwhenok(formatReflectValue(vj))
-			if errc != nil {
-				break
-			}
-		}
-		return formatted, errc
+		return formatStructFields(value)
 	case reflect.Array:
 		//log.Print("ArrayType")
 		fallthrough
@@ -331,7 +549,13 @@ func formatValue(x interface{}) ([]string, error) {
 		return formatted, errc
 	case reflect.Map:
 		//log.Print("MapType")
-		return formatted, ErrorUnimplemented
+		for _, vj := range sortedMapValues(value) {
+			errc = appendwhenok(formatReflectValue(vj))
+			if errc != nil {
+				break
+			}
+		}
+		return formatted, errc
 	default:
 		errc = appendwhenok(formatReflectValue(value))
 		return formatted, errc
@@ -346,15 +570,7 @@ func formatValue(x interface{}) ([]string, error) {
 	case reflect.Struct:
 		switch kind {
 		case reflect.Ptr:
-			n = eVal.NumField()
-			for j := 0; j < n; j++ {
-				var vj = eVal.Field(j)
-				errc = appendwhenok(formatReflectValue(vj))
-				if errc != nil {
-					break
-				}
-			}
-			return formatted, errc
+			return formatStructFields(eVal)
 		default:
 			errc = ErrorStruct
 		}
@@ -374,7 +590,13 @@ func formatValue(x interface{}) ([]string, error) {
 		return formatted, errc
 	case reflect.Map:
 		//log.Print("MapType")
-		return formatted, ErrorUnimplemented
+		for _, vj := range sortedMapValues(eVal) {
+			errc = appendwhenok(formatReflectValue(vj))
+			if errc != nil {
+				break
+			}
+		}
+		return formatted, errc
 	default:
 		errc = appendwhenok(formatReflectValue(eVal))
 	}
@@ -402,5 +624,5 @@ func FormatRow(x ...interface{}) Row {
 			break
 		}
 	}
-	return Row{formatted, err}
+	return Row{Fields: formatted, Error: err}
 }
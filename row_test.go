@@ -0,0 +1,60 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"testing"
+)
+
+func TestFormatComplex(T *testing.T) {
+	var (
+		a   complex128
+		b   complex64
+		row = Row{Fields: []string{"(1+2i)", "-0.5i"}}
+	)
+	if n, err := row.Format(&a, &b); err != nil || n != 2 || a != 1+2i || b != -0.5i {
+		T.Errorf("Unexpected format %d %v %v, %v", n, a, b, err)
+	}
+	if _, err := (Row{Fields: []string{"1+"}}).Format(&a); err == nil {
+		T.Error("No error for an invalid complex number")
+	}
+	if row := FormatRow(a, b); row.Error != nil || !equalStrings(row.Fields, []string{"(1+2i)", "(0-0.5i)"}) {
+		T.Errorf("Unexpected row %v", row)
+	}
+
+	type sample struct {
+		T int
+		Z complex64 `csv:"z,parts"`
+	}
+	var s sample
+	row = Row{Fields: []string{"3", "0.1", "-2"}}
+	if n, err := row.Format(&s); err != nil || n != 3 || s != (sample{3, complex(0.1, -2)}) {
+		T.Errorf("Unexpected format %d %v, %v", n, s, err)
+	}
+	if formatted := FormatRow(s); formatted.Error != nil || !equalStrings(formatted.Fields, row.Fields) {
+		T.Errorf("Unexpected row %v", formatted)
+	}
+	if _, err := (Row{Fields: []string{"3", "0.1"}}).Format(&s); err != ErrorIndex {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestFormatDefaults(T *testing.T) {
+	type item struct {
+		Name string
+		Qty  int    `csv:"qty,default=1"`
+		Unit string `csv:"unit,default=each"`
+	}
+	var it item
+	if n, err := (Row{Fields: []string{"nut"}}).Format(&it); err != nil || n != 1 || it != (item{"nut", 1, "each"}) {
+		T.Errorf("Unexpected format %d %+v, %v", n, it, err)
+	}
+	if n, err := (Row{Fields: []string{"bolt", "", "box"}}).Format(&it); err != nil || n != 3 || it != (item{"bolt", 1, "box"}) {
+		T.Errorf("Unexpected format %d %+v, %v", n, it, err)
+	}
+	if _, err := (Row{}).Format(&it); err != ErrorIndex {
+		T.Errorf("Unexpected error %v for a field without a default", err)
+	}
+}
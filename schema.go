@@ -0,0 +1,219 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: schema.go
+*  Description: Describing the columns of CSV data.
+ */
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//  The columns of CSV data. A Schema can be serialized with encoding/json.
+type Schema struct {
+	HasHeader bool           `json:"header"`
+	Null      string         `json:"null,omitempty"`
+	Columns   []SchemaColumn `json:"columns"`
+	//  Columns whose combined fields are unique in each row.
+	PrimaryKey []string `json:"primary_key,omitempty"`
+}
+
+//  A column of a Schema. Fields of the column are checked against its
+//  constraints by a Validator. Constraints that are zero are not checked.
+type SchemaColumn struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Layout   string    `json:"layout,omitempty"` // Time layout of datetimes.
+	Required bool      `json:"required"`         // Fields can not be NULL.
+	Unique   bool      `json:"unique,omitempty"` // Fields can not repeat.
+	//  The only values fields may have.
+	Values []string `json:"values,omitempty"`
+	//  Inclusive bounds of integer and number fields.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	//  A regular expression (see regexp) that fields must match entirely.
+	Pattern string `json:"pattern,omitempty"`
+	//  The maximum number of characters of a field.
+	MaxLength int `json:"max_length,omitempty"`
+}
+
+//  Time layouts tried by InferSchema, after that of the Reader's Config.
+var InferTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+	"15:04:05",
+	time.RFC1123,
+}
+
+//  Guess a Schema from the first sampleRows rows read from r (all rows if
+//  sampleRows is not positive). Columns are required when none of their
+//  sampled fields are NULL (or empty). Unless r has already read a header,
+//  the first row is compared to the others to decide if it is a header:
+//  it is one when its fields do not have the types of the columns below
+//  them. When no column has a type other than string, r's HasHeader is
+//  trusted. Columns of CSV data without a header are named by number,
+//  starting at 1. The rows sampled are consumed from r. When r's HasHeader
+//  is set but its first row is decided to be data, r's header becomes the
+//  numbered column names, so rows read from r afterward are not taken for
+//  its header.
+func InferSchema(r *Reader, sampleRows int) (*Schema, error) {
+	var (
+		first  []string
+		rows   [][]string
+		header = r.header
+	)
+	for sampleRows <= 0 || len(rows) < sampleRows {
+		var row = r.readRow()
+		if row.HasEOF() {
+			break
+		} else if row.HasError() {
+			return nil, row.Error
+		}
+		if header == nil && first == nil {
+			first = row.Fields
+			continue
+		}
+		rows = append(rows, row.Fields)
+	}
+	var width = len(header) + len(first)
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	var (
+		s       = &Schema{HasHeader: header != nil, Null: r.Null}
+		guesses = make([]*typeGuess, width)
+		nulls   = make([]bool, width)
+		layouts = append([]string{r.TimeLayout}, InferTimeLayouts...)
+	)
+	var isNull = func(field string) bool {
+		return field == "" || r.IsNull(field)
+	}
+	var guess = func() {
+		for i := range guesses {
+			guesses[i] = newTypeGuess(layouts...)
+			nulls[i] = false
+			for _, row := range rows {
+				if i >= len(row) || isNull(row[i]) {
+					nulls[i] = true
+				} else {
+					guesses[i].add(row[i])
+				}
+			}
+		}
+	}
+	guess()
+
+	// Decide whether the first row is a header.
+	if first != nil {
+		var votes int
+		for i, g := range guesses {
+			if i >= len(first) || isNull(first[i]) || g.Type() == TypeString {
+				continue
+			}
+			var t, layout = g.Type(), g.Layout()
+			g.add(first[i])
+			if g.Type() == t && g.Layout() == layout {
+				votes--
+			} else {
+				votes++
+			}
+		}
+		if votes > 0 || votes == 0 && r.HasHeader {
+			s.HasHeader = true
+			header = first
+			if r.HasHeader {
+				r.header = Header(first)
+			}
+		} else {
+			rows = append([][]string{first}, rows...)
+		}
+		guess()
+	}
+
+	for i, g := range guesses {
+		var col = SchemaColumn{
+			Name:     strconv.Itoa(i + 1),
+			Type:     g.Type(),
+			Required: !nulls[i] && len(rows) > 0}
+		if i < len(header) {
+			col.Name = header[i]
+		}
+		if col.Type == TypeDatetime {
+			col.Layout = g.Layout()
+		}
+		s.Columns = append(s.Columns, col)
+	}
+	if r.HasHeader && r.header == nil && first != nil {
+		r.header = make(Header, len(s.Columns))
+		for i := range s.Columns {
+			r.header[i] = s.Columns[i].Name
+		}
+	}
+	return s, nil
+}
+
+//  Set the HasHeader and Null fields of a Config to those of the schema.
+func (s *Schema) Configure(c *Config) {
+	c.HasHeader = s.HasHeader
+	c.Null = s.Null
+}
+
+//  Returns the index of the named column, or -1.
+func (s *Schema) Index(name string) int {
+	for i := range s.Columns {
+		if s.Columns[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//  Parse a non-NULL field of the column as an int64, float64, bool,
+//  time.Time or string, depending on the column's type.
+func (col *SchemaColumn) Parse(field string) (interface{}, error) {
+	var trimmed = strings.TrimSpace(field)
+	switch col.Type {
+	case TypeInteger:
+		return strconv.ParseInt(trimmed, 10, 64)
+	case TypeNumber:
+		return strconv.ParseFloat(trimmed, 64)
+	case TypeBoolean:
+		return strconv.ParseBool(trimmed)
+	case TypeDatetime:
+		var layout = col.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, trimmed)
+	}
+	return field, nil
+}
+
+//  Parse the fields of a row according to the schema (see
+//  SchemaColumn.Parse). NULL, empty and missing fields are nil. Fields past
+//  the last column are ignored. Errors are returned as a *LineError.
+func (s *Schema) Decode(r Row) ([]interface{}, error) {
+	var values = make([]interface{}, len(s.Columns))
+	for i := range s.Columns {
+		var col = &s.Columns[i]
+		if i >= len(r.Fields) || r.Fields[i] == "" || r.Fields[i] == s.Null {
+			continue
+		}
+		var v, err = col.Parse(r.Fields[i])
+		if err != nil {
+			return nil, &LineError{Line: r.Line, Column: col.Name, Err: err}
+		}
+		values[i] = v
+	}
+	return values, nil
+}
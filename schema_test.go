@@ -0,0 +1,97 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSchemaData = "id,score,ok,day,name\n" +
+	"1,2.5,true,2011-01-02,ann\n" +
+	"2,3,false,2011-01-03,\n" +
+	"3,,true,2011-01-04,carl\n"
+
+func TestInferSchema(T *testing.T) {
+	var s, err = InferSchema(StringReader(testSchemaData, nil), 10)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if !s.HasHeader || len(s.Columns) != 5 {
+		T.Fatalf("Unexpected schema %+v", s)
+	}
+	var expect = []SchemaColumn{
+		{Name: "id", Type: TypeInteger, Required: true},
+		{Name: "score", Type: TypeNumber},
+		{Name: "ok", Type: TypeBoolean, Required: true},
+		{Name: "day", Type: TypeDatetime, Layout: "2006-01-02", Required: true},
+		{Name: "name", Type: TypeString},
+	}
+	for i, col := range s.Columns {
+		if !reflect.DeepEqual(col, expect[i]) {
+			T.Errorf("Unexpected column %+v (!= %+v)", col, expect[i])
+		}
+	}
+
+	var data []byte
+	if data, err = json.Marshal(s); err != nil {
+		T.Fatal(err)
+	}
+	var decoded Schema
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		T.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Columns[3], expect[3]) {
+		T.Errorf("Unexpected decoded column %+v", decoded.Columns[3])
+	}
+
+	// Decode the file with the schema.
+	var config = NewConfig()
+	decoded.Configure(config)
+	var r = StringReader(testSchemaData, config)
+	var row = r.ReadRow()
+	var values []interface{}
+	if values, err = decoded.Decode(row); err != nil {
+		T.Fatal(err)
+	}
+	var day = time.Date(2011, 1, 2, 0, 0, 0, 0, time.UTC)
+	if values[0] != int64(1) || values[1] != 2.5 || values[2] != true ||
+		!values[3].(time.Time).Equal(day) || values[4] != "ann" {
+		T.Errorf("Unexpected values %v", values)
+	}
+}
+
+func TestInferSchemaNoHeader(T *testing.T) {
+	var s, err = InferSchema(StringReader("1,a\n2,b\n3,\n", nil), 0)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if s.HasHeader || s.Columns[0].Name != "1" || s.Columns[0].Type != TypeInteger ||
+		!s.Columns[0].Required || s.Columns[1].Required {
+		T.Errorf("Unexpected schema %+v", s)
+	}
+}
+
+func TestInferSchemaNoHeaderThenRead(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	var r = StringReader("1,a\n2,b\n3,c\n4,d\n5,e\n", config)
+	var s, err = InferSchema(r, 2)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if s.HasHeader {
+		T.Fatalf("Unexpected schema %+v", s)
+	}
+	var row = r.ReadRow()
+	if row.Error != nil {
+		T.Fatal(row.Error)
+	}
+	if !equalStrings(row.Fields, []string{"4", "d"}) || !equalStrings(row.Header, []string{"1", "2"}) {
+		T.Errorf("Unexpected row %v with header %v", row.Fields, row.Header)
+	}
+}
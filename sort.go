@@ -0,0 +1,456 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: sort.go
+*  Description: External merge sorting of CSV data.
+ */
+import (
+	"container/heap"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//  How the fields of a sort key are ordered.
+type SortType int
+
+const (
+	SortString  SortType = iota // Lexical order.
+	SortNumeric                 // Numeric order of floating point values.
+	SortTime                    // Chronological order.
+)
+
+//  A column to sort by. Fields that can not be parsed as the key's type
+//  sort before those that can, or after them when Desc is set.
+type SortKey struct {
+	Column string   // Column name (requires a header).
+	Index  int      // Column index, used when Column is empty.
+	Type   SortType // How fields are compared.
+	Layout string   // Time layout for SortTime (Config.TimeLayout if empty).
+	Desc   bool     // Sort in descending order.
+}
+
+//  Options controlling Sort.
+type SortOptions struct {
+	//  Configuration of the input and output (DefaultConfig if nil).
+	Config *Config
+	//  Approximate number of bytes of rows held in memory. Larger inputs
+	//  are sorted in runs that are spilled to temporary files and then
+	//  merged. Defaults to DefaultSortMemory when less than one.
+	Memory int64
+	//  Directory for temporary files (see ioutil.TempFile).
+	TempDir string
+	//  The most spilled runs merged at once, which is the most temporary
+	//  files held open. More runs are merged in several passes. Defaults
+	//  to DefaultSortMergeWidth when less than two.
+	MergeWidth int
+}
+
+//  The default memory budget of Sort.
+var DefaultSortMemory int64 = 64 << 20
+
+//  The default number of runs Sort merges at once.
+var DefaultSortMergeWidth = 64
+
+//  Sort CSV data by the given keys. The sort is stable. Leading comments
+//  and the header (when opts.Config has HasHeader set) are written to out
+//  before the sorted rows. Rows are buffered in memory up to the memory
+//  budget, beyond which sorted runs are written to temporary files and
+//  merged, at most opts.MergeWidth at a time. Comments in the body are discarded. Blank lines are sorted as
+//  rows with a single empty field.
+func Sort(in io.Reader, out io.Writer, keys []SortKey, opts *SortOptions) error {
+	if opts == nil {
+		opts = new(SortOptions)
+	}
+	var (
+		config = opts.Config
+		memory = opts.Memory
+		width  = opts.MergeWidth
+	)
+	if config == nil {
+		config = NewConfig()
+	}
+	if memory < 1 {
+		memory = DefaultSortMemory
+	}
+	if width < 2 {
+		width = DefaultSortMergeWidth
+	}
+	var (
+		csvr        = NewReader(in, config)
+		csvw        = NewWriter(out, config)
+		header, err = csvr.Header()
+	)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var s = &sorter{config: config, keys: keys}
+	if s.indices, err = resolveSortKeys(header, keys); err != nil {
+		return err
+	}
+
+	// Read sorted runs, spilling them to temporary files when they grow
+	// beyond the memory budget.
+	var (
+		run   []*sortRow
+		size  int64
+		files []*os.File
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	var first = csvr.ReadRow()
+	if first.HasError() && !first.HasEOF() {
+		return first.Error
+	}
+	if _, err = csvw.WriteComments(csvr.LeadingComments()...); err != nil {
+		return err
+	}
+	if header != nil {
+		if _, err = csvw.WriteRow(header...); err != nil {
+			return err
+		}
+	}
+	for r := first; !r.HasEOF(); r = csvr.ReadRow() {
+		if r.HasError() {
+			return r.Error
+		}
+		if len(r.Fields) == 0 {
+			// A blank line. Writing no fields would drop it, so it is
+			// kept as a single empty field.
+			r.Fields = []string{""}
+		}
+		run = append(run, s.newRow(r.Fields))
+		size += sortRowSize(r.Fields)
+		if size >= memory {
+			var f *os.File
+			if f, err = s.spill(run, opts.TempDir); err != nil {
+				return err
+			}
+			files = append(files, f)
+			run, size = run[:0], 0
+		}
+	}
+	s.sort(run)
+	if len(files) == 0 {
+		for _, row := range run {
+			if _, err = csvw.WriteRow(row.fields...); err != nil {
+				return err
+			}
+		}
+		return csvw.Flush()
+	}
+
+	// Merge consecutive spilled runs into longer ones until they can all
+	// be merged at once, keeping them in input order so the merge is
+	// stable.
+	for len(files) > width {
+		for i := 0; i < len(files); i++ {
+			var end = i + width
+			if end > len(files) {
+				end = len(files)
+			}
+			var f *os.File
+			if f, err = s.mergeFiles(files[i:end], opts.TempDir); err != nil {
+				return err
+			}
+			for _, g := range files[i:end] {
+				g.Close()
+				os.Remove(g.Name())
+			}
+			files = append(append(files[:i:i], f), files[end:]...)
+		}
+	}
+
+	// Merge the spilled runs, followed by the run still in memory.
+	var runs = s.fileRuns(files)
+	if len(run) > 0 {
+		runs = append(runs, &sortRun{i: len(files), mem: run[1:], row: run[0]})
+	}
+	if err = s.merge(csvw, runs); err != nil {
+		return err
+	}
+	return csvw.Flush()
+}
+
+//  Readers of the spilled runs in files, in order.
+func (s *sorter) fileRuns(files []*os.File) []*sortRun {
+	var runs = make([]*sortRun, len(files))
+	for i, f := range files {
+		runs[i] = &sortRun{i: i, csvr: NewReader(f, spillConfig(s.config))}
+	}
+	return runs
+}
+
+//  Merge spilled runs into a new temporary file, positioned at its start.
+func (s *sorter) mergeFiles(files []*os.File, dir string) (*os.File, error) {
+	var f, err = ioutil.TempFile(dir, "csvutil-sort")
+	if err != nil {
+		return nil, err
+	}
+	var csvw = NewWriter(f, spillConfig(s.config))
+	if err = s.merge(csvw, s.fileRuns(files)); err == nil {
+		err = csvw.Flush()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+//  Write the rows of sorted runs in order. Runs from memory must have
+//  their current row set; runs from files are read from their start.
+func (s *sorter) merge(csvw *Writer, runs []*sortRun) error {
+	var h = &sortHeap{s: s}
+	for _, rr := range runs {
+		if rr.csvr == nil {
+			heap.Push(h, rr)
+		} else if err := h.push(rr); err != nil {
+			return err
+		}
+	}
+	for h.Len() > 0 {
+		var rr = h.runs[0]
+		if _, err := csvw.WriteRow(rr.row.fields...); err != nil {
+			return err
+		}
+		if err := rr.next(s); err == io.EOF {
+			heap.Pop(h)
+		} else if err != nil {
+			return err
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return nil
+}
+
+//  Resolve the column index of each key.
+func resolveSortKeys(header Header, keys []SortKey) ([]int, error) {
+	var indices = make([]int, len(keys))
+	for i, k := range keys {
+		indices[i] = k.Index
+		if k.Column != "" {
+			var idx, err = header.Indices(k.Column)
+			if err != nil {
+				return nil, err
+			}
+			indices[i] = idx[0]
+		}
+	}
+	return indices, nil
+}
+
+//  The configuration used for temporary files, which hold only data rows.
+func spillConfig(c *Config) *Config {
+	var spill = new(Config)
+	*spill = *c
+	spill.Trim = false
+	spill.Comments = false
+	spill.HasHeader = false
+	return spill
+}
+
+//  Approximate memory used by a row.
+func sortRowSize(fields []string) int64 {
+	var n = int64(64 + 16*len(fields))
+	for _, f := range fields {
+		n += int64(len(f))
+	}
+	return n
+}
+
+type sorter struct {
+	config  *Config
+	keys    []SortKey
+	indices []int
+}
+
+//  A row with its keys parsed.
+type sortRow struct {
+	fields []string
+	keys   []sortValue
+}
+
+type sortValue struct {
+	s  string
+	f  float64
+	t  time.Time
+	ok bool // The field was parsed.
+}
+
+func (s *sorter) newRow(fields []string) *sortRow {
+	var row = &sortRow{fields, make([]sortValue, len(s.keys))}
+	for i, k := range s.keys {
+		var (
+			j = s.indices[i]
+			v = &row.keys[i]
+		)
+		if j >= len(fields) {
+			continue
+		}
+		var err error
+		switch k.Type {
+		case SortNumeric:
+			v.f, err = strconv.ParseFloat(fields[j], 64)
+		case SortTime:
+			var layout = k.Layout
+			if layout == "" {
+				layout = s.config.TimeLayout
+			}
+			v.t, err = time.Parse(layout, fields[j])
+		default:
+			v.s = fields[j]
+		}
+		v.ok = err == nil
+	}
+	return row
+}
+
+func (s *sorter) compare(a, b *sortRow) int {
+	for i, k := range s.keys {
+		var (
+			x, y = a.keys[i], b.keys[i]
+			cmp  int
+		)
+		switch {
+		case !x.ok || !y.ok:
+			if x.ok {
+				cmp = 1
+			} else if y.ok {
+				cmp = -1
+			}
+		case k.Type == SortNumeric:
+			if x.f < y.f {
+				cmp = -1
+			} else if x.f > y.f {
+				cmp = 1
+			}
+		case k.Type == SortTime:
+			if x.t.Before(y.t) {
+				cmp = -1
+			} else if x.t.After(y.t) {
+				cmp = 1
+			}
+		default:
+			cmp = strings.Compare(x.s, y.s)
+		}
+		if k.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func (s *sorter) sort(run []*sortRow) {
+	sort.SliceStable(run, func(i, j int) bool {
+		return s.compare(run[i], run[j]) < 0
+	})
+}
+
+//  Sort a run and write it to a new temporary file, positioned at its
+//  start.
+func (s *sorter) spill(run []*sortRow, dir string) (*os.File, error) {
+	s.sort(run)
+	var f, err = ioutil.TempFile(dir, "csvutil-sort")
+	if err != nil {
+		return nil, err
+	}
+	var csvw = NewWriter(f, spillConfig(s.config))
+	for _, row := range run {
+		if _, err = csvw.WriteRow(row.fields...); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = csvw.Flush()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+//  A sorted run being merged, either from a file or from memory.
+type sortRun struct {
+	i    int // Order of the run in the input.
+	csvr *Reader
+	mem  []*sortRow
+	row  *sortRow // The current row.
+}
+
+func (rr *sortRun) next(s *sorter) error {
+	if rr.csvr == nil {
+		if len(rr.mem) == 0 {
+			return io.EOF
+		}
+		rr.row, rr.mem = rr.mem[0], rr.mem[1:]
+		return nil
+	}
+	var r = rr.csvr.ReadRow()
+	if r.HasError() {
+		return r.Error
+	}
+	if len(r.Fields) == 0 {
+		// Rows without fields are not written, so this was a single
+		// empty field.
+		r.Fields = []string{""}
+	}
+	rr.row = s.newRow(r.Fields)
+	return nil
+}
+
+//  A heap of runs ordered by their current rows. Ties are broken by the
+//  order of runs in the input, keeping the merge stable.
+type sortHeap struct {
+	s    *sorter
+	runs []*sortRun
+}
+
+func (h *sortHeap) push(rr *sortRun) error {
+	var err = rr.next(h.s)
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	heap.Push(h, rr)
+	return nil
+}
+
+func (h *sortHeap) Len() int      { return len(h.runs) }
+func (h *sortHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *sortHeap) Less(i, j int) bool {
+	var cmp = h.s.compare(h.runs[i].row, h.runs[j].row)
+	return cmp < 0 || cmp == 0 && h.runs[i].i < h.runs[j].i
+}
+func (h *sortHeap) Push(x interface{}) { h.runs = append(h.runs, x.(*sortRun)) }
+func (h *sortHeap) Pop() interface{} {
+	var rr = h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return rr
+}
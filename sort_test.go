@@ -0,0 +1,126 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSort(T *testing.T) {
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Comments = true
+	var input = "# exported\nname,score,day\n" +
+		"bob,10,2011-07-02T00:00:00Z\n" +
+		"alice,9.5,2011-07-01T00:00:00Z\n" +
+		"chris,10,2011-06-30T00:00:00Z\n" +
+		"dana,x,2011-07-03T00:00:00Z\n"
+	var tests = []struct {
+		keys   []SortKey
+		expect string
+	}{
+		{[]SortKey{{Column: "name"}}, "alice,bob,chris,dana"},
+		{[]SortKey{{Column: "score", Type: SortNumeric}}, "dana,alice,bob,chris"},
+		{[]SortKey{{Column: "score", Type: SortNumeric, Desc: true}, {Column: "day", Type: SortTime}},
+			"chris,bob,alice,dana"},
+		{[]SortKey{{Index: 2, Type: SortTime, Desc: true}}, "dana,bob,alice,chris"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		var err = Sort(strings.NewReader(input), &out, test.keys, &SortOptions{Config: config})
+		if err != nil {
+			T.Error(err)
+			continue
+		}
+		var lines = strings.Split(out.String(), "\n")
+		if lines[0] != "# exported" || lines[1] != "name,score,day" {
+			T.Errorf("Leading lines not preserved: %q", lines[:2])
+		}
+		var names []string
+		for _, line := range lines[2:] {
+			if line != "" {
+				names = append(names, strings.SplitN(line, ",", 2)[0])
+			}
+		}
+		if got := strings.Join(names, ","); got != test.expect {
+			T.Errorf("%v: sorted %q (!= %q)", test.keys, got, test.expect)
+		}
+	}
+}
+
+func TestSortExternal(T *testing.T) {
+	var (
+		input  bytes.Buffer
+		rng    = rand.New(rand.NewSource(1))
+		counts = make(map[int]int)
+	)
+	for i := 0; i < 2000; i++ {
+		var k = rng.Intn(100)
+		fmt.Fprintf(&input, "%d,%d\n", k, counts[k])
+		counts[k]++
+	}
+	var dir, err = ioutil.TempDir("", "csvutil-sort-test")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var keys = []SortKey{{Index: 0, Type: SortNumeric}}
+	// Small merge widths need several passes over the spilled runs.
+	for _, width := range []int{0, 2, 3} {
+		var out bytes.Buffer
+		var opts = &SortOptions{Memory: 4096, MergeWidth: width, TempDir: dir}
+		if err = Sort(bytes.NewReader(input.Bytes()), &out, keys, opts); err != nil {
+			T.Fatal(err)
+		}
+		if names, _ := ioutil.ReadDir(dir); len(names) != 0 {
+			T.Errorf("Width %d: %d temporary files left", width, len(names))
+		}
+		var rows [][]string
+		if rows, err = Read(&out); err != nil {
+			T.Fatal(err)
+		}
+		if len(rows) != 2000 {
+			T.Fatalf("Width %d: sorted %d rows (!= 2000)", width, len(rows))
+		}
+		// Rows must be ordered by key, and by input order (the second
+		// field) within a key.
+		for i := 1; i < len(rows); i++ {
+			var (
+				k0, k1 = atoi(T, rows[i-1][0]), atoi(T, rows[i][0])
+				s0, s1 = atoi(T, rows[i-1][1]), atoi(T, rows[i][1])
+			)
+			if k0 > k1 || k0 == k1 && s0 >= s1 {
+				T.Fatalf("Width %d: rows %d and %d out of order: %v %v", width, i-1, i, rows[i-1], rows[i])
+			}
+		}
+	}
+}
+
+func atoi(T *testing.T, s string) int {
+	var i int
+	if _, err := fmt.Sscan(s, &i); err != nil {
+		T.Fatal(err)
+	}
+	return i
+}
+
+func TestSortBlankRows(T *testing.T) {
+	for _, memory := range []int64{0, 1} {
+		var out bytes.Buffer
+		var err = Sort(strings.NewReader("b\n\na\n\n"), &out, []SortKey{{Index: 0}}, &SortOptions{Memory: memory})
+		if err != nil {
+			T.Fatal(err)
+		}
+		if out.String() != "\n\na\nb\n" {
+			T.Errorf("Memory %d: unexpected output %q", memory, out.String())
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: split.go
+*  Description: Splitting CSV data into several files.
+ */
+import (
+	"errors"
+	"os"
+	"unicode/utf8"
+)
+
+//  Decides when Split starts a new part. Exactly one field must be set.
+type SplitPolicy struct {
+	Rows   int    // Rows per part.
+	Bytes  int64  // Maximum size of a part, unless it holds a single row.
+	Column string // One part per distinct value of the named column.
+}
+
+//  Returned by Split for a policy without exactly one field set.
+var ErrorSplitPolicy = errors.New("Invalid split policy.")
+
+//  The most files Split keeps open when splitting by column (at least one).
+var SplitMaxOpen = 64
+
+//  A file written by Split.
+type SplitPart struct {
+	Path  string
+	Key   string // Value of the policy's Column, if any.
+	Rows  int    // Rows, not counting the header.
+	Bytes int64  // Size of the file.
+}
+
+//  Split the rows read from r into files created at the paths returned by
+//  namer, which is given the number of the part (starting at 1) and, when
+//  splitting by column, the column's value (which namer must make safe to
+//  use in a path). The leading comments and header of r are repeated in
+//  each part, which is written with r's Config. Splitting by column keeps
+//  up to SplitMaxOpen files open. Beyond that, the least recently written
+//  file is closed, and reopened to append to if its value appears again.
+//  Returns the parts written, even when an error occurs.
+func Split(r *Reader, policy SplitPolicy, namer func(part int, key string) string) ([]SplitPart, error) {
+	var set int
+	for _, ok := range []bool{policy.Rows > 0, policy.Bytes > 0, policy.Column != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, ErrorSplitPolicy
+	}
+	var header, err = r.Header()
+	if err != nil {
+		return nil, err
+	}
+	var column = -1
+	if policy.Column != "" {
+		if header == nil {
+			return nil, ErrorNoHeader
+		}
+		var idx []int
+		if idx, err = header.Indices(policy.Column); err != nil {
+			return nil, err
+		}
+		column = idx[0]
+	}
+	var s = &splitter{r: r, header: header, namer: namer}
+	if column >= 0 {
+		s.open = make(map[string]*splitFile)
+		s.keys = make(map[string]int)
+	}
+	r.Do(func(row Row) bool {
+		if err = row.Error; err != nil {
+			return false
+		}
+		var (
+			size = rowSize(row.Fields, r.Sep)
+			key  string
+			f    *splitFile
+		)
+		switch {
+		case column >= 0:
+			if column >= len(row.Fields) {
+				err = &LineError{Line: row.Line, Column: policy.Column, Err: ErrorIndex}
+				return false
+			}
+			key = row.Fields[column]
+			if f, err = s.file(key); err != nil {
+				return false
+			}
+		case policy.Rows > 0:
+			if f = s.current; f != nil && s.parts[f.part].Rows >= policy.Rows {
+				f = nil
+			}
+		default:
+			f = s.current
+			if f != nil && s.parts[f.part].Rows > 0 && s.parts[f.part].Bytes+size > policy.Bytes {
+				f = nil
+			}
+		}
+		if f == nil {
+			if f, err = s.create(key); err != nil {
+				return false
+			}
+		}
+		var n int
+		n, err = f.w.WriteRow(row.Fields...)
+		s.parts[f.part].Rows++
+		s.parts[f.part].Bytes += int64(n)
+		return err == nil
+	})
+	if cerr := s.closeAll(); err == nil {
+		err = cerr
+	}
+	return s.parts, err
+}
+
+type splitter struct {
+	r       *Reader
+	header  Header
+	namer   func(int, string) string
+	parts   []SplitPart
+	current *splitFile
+	open    map[string]*splitFile // Open files by key, when splitting by column.
+	keys    map[string]int        // Part of each key, when splitting by column.
+	clock   int                   // Counts uses of files, to find the oldest.
+}
+
+type splitFile struct {
+	part int // Index into the splitter's parts.
+	f    *os.File
+	w    *Writer
+	used int // The splitter's clock when the file was last used.
+}
+
+//  The open file for a key, creating or reopening it, and closing the least
+//  recently used file when SplitMaxOpen files are open.
+func (s *splitter) file(key string) (*splitFile, error) {
+	s.clock++
+	if sf := s.open[key]; sf != nil {
+		sf.used = s.clock
+		return sf, nil
+	}
+	if len(s.open) > 0 && len(s.open) >= SplitMaxOpen {
+		var (
+			oldest    *splitFile
+			oldestKey string
+		)
+		for k, sf := range s.open {
+			if oldest == nil || sf.used < oldest.used {
+				oldest, oldestKey = sf, k
+			}
+		}
+		delete(s.open, oldestKey)
+		if err := oldest.close(); err != nil {
+			return nil, err
+		}
+	}
+	var sf *splitFile
+	if part, ok := s.keys[key]; ok {
+		var f, err = os.OpenFile(s.parts[part].Path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return nil, err
+		}
+		sf = &splitFile{part: part, f: f, w: NewWriter(f, s.r.Config)}
+	} else {
+		var err error
+		if sf, err = s.create(key); err != nil {
+			return nil, err
+		}
+		s.keys[key] = sf.part
+	}
+	sf.used = s.clock
+	s.open[key] = sf
+	return sf, nil
+}
+
+//  Create the next part, closing the current one unless splitting by
+//  column.
+func (s *splitter) create(key string) (*splitFile, error) {
+	if s.current != nil && s.open == nil {
+		var err = s.current.close()
+		s.current = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+	var path = s.namer(len(s.parts)+1, key)
+	var f, err = os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var sf = &splitFile{part: len(s.parts), f: f, w: NewWriter(f, s.r.Config)}
+	s.parts = append(s.parts, SplitPart{Path: path, Key: key})
+	var n int
+	if n, err = sf.w.WriteComments(s.r.LeadingComments()...); err == nil && s.header != nil {
+		s.parts[sf.part].Bytes += int64(n)
+		n, err = sf.w.WriteRow(s.header...)
+	}
+	s.parts[sf.part].Bytes += int64(n)
+	if err != nil {
+		sf.f.Close()
+		return nil, err
+	}
+	s.current = sf
+	return sf, nil
+}
+
+func (f *splitFile) close() error {
+	var err = f.w.Flush()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *splitter) closeAll() error {
+	var err error
+	if s.open == nil && s.current != nil {
+		err = s.current.close()
+	}
+	for _, f := range s.open {
+		if cerr := f.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//  The number of bytes taken by a row written by a Writer.
+func rowSize(fields []string, sep rune) int64 {
+	if len(fields) == 0 {
+		return 0
+	}
+	var size = (len(fields)-1)*utf8.RuneLen(sep) + 1
+	for _, field := range fields {
+		size += len(field)
+	}
+	return int64(size)
+}
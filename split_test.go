@@ -0,0 +1,90 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplit(T *testing.T) {
+	var dir, err = ioutil.TempDir("", "csvutil-split")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var (
+		config = NewConfig()
+		input  = "# export\nk,v\na,1\nb,2\na,3\nc,4\nb,5\n"
+		namer  = func(prefix string) func(int, string) string {
+			return func(part int, key string) string {
+				return filepath.Join(dir, fmt.Sprintf("%s-%d%s.csv", prefix, part, key))
+			}
+		}
+	)
+	config.HasHeader = true
+	config.Comments = true
+	var tests = []struct {
+		name   string
+		policy SplitPolicy
+		keys   []string
+		files  []string
+	}{
+		{"rows", SplitPolicy{Rows: 2}, []string{"", "", ""}, []string{
+			"# export\nk,v\na,1\nb,2\n",
+			"# export\nk,v\na,3\nc,4\n",
+			"# export\nk,v\nb,5\n"}},
+		{"bytes", SplitPolicy{Bytes: 21}, []string{"", "", ""}, []string{
+			"# export\nk,v\na,1\nb,2\n",
+			"# export\nk,v\na,3\nc,4\n",
+			"# export\nk,v\nb,5\n"}},
+		{"column", SplitPolicy{Column: "k"}, []string{"a", "b", "c"}, []string{
+			"# export\nk,v\na,1\na,3\n",
+			"# export\nk,v\nb,2\nb,5\n",
+			"# export\nk,v\nc,4\n"}},
+		// With one open file, a and b are closed and reopened.
+		{"lru", SplitPolicy{Column: "k"}, []string{"a", "b", "c"}, []string{
+			"# export\nk,v\na,1\na,3\n",
+			"# export\nk,v\nb,2\nb,5\n",
+			"# export\nk,v\nc,4\n"}},
+	}
+	defer func(n int) { SplitMaxOpen = n }(SplitMaxOpen)
+	for _, test := range tests {
+		if SplitMaxOpen = 64; test.name == "lru" {
+			SplitMaxOpen = 1
+		}
+		var parts, err = Split(StringReader(input, config), test.policy, namer(test.name))
+		if err != nil {
+			T.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(parts) != len(test.files) {
+			T.Errorf("%s: %d parts (!= %d)", test.name, len(parts), len(test.files))
+			continue
+		}
+		for i, part := range parts {
+			var data, err = ioutil.ReadFile(part.Path)
+			if err != nil {
+				T.Error(err)
+				continue
+			}
+			if string(data) != test.files[i] {
+				T.Errorf("%s: part %d\n%s\n(!=\n%s)", test.name, i+1, data, test.files[i])
+			}
+			if part.Key != test.keys[i] {
+				T.Errorf("%s: part %d key %q (!= %q)", test.name, i+1, part.Key, test.keys[i])
+			}
+			if part.Bytes != int64(len(data)) {
+				T.Errorf("%s: part %d bytes %d (!= %d)", test.name, i+1, part.Bytes, len(data))
+			}
+		}
+	}
+	if _, err = Split(StringReader(input, config), SplitPolicy{Rows: 1, Bytes: 1}, namer("x")); err != ErrorSplitPolicy {
+		T.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: sql.go
+*  Description: Conversion between CSV data and database/sql.
+ */
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//  Options controlling how WriteSQLRows writes query results.
+type SQLOptions struct {
+	//  Do not write a header row of column names.
+	NoHeader bool
+}
+
+//  Write the rows of a query result with a Writer. Unless opts.NoHeader is
+//  set, a header is first written using the names from rows.Columns().
+//  NULL values are written as the Writer's Null field, times are formatted
+//  with its TimeLayout, and numbers are formatted like FormatRow formats
+//  them. The rows are not closed and the Writer is not flushed. Returns
+//  the number of bytes written and any error encountered.
+func WriteSQLRows(w *Writer, rows *sql.Rows, opts *SQLOptions) (int, error) {
+	if opts == nil {
+		opts = new(SQLOptions)
+	}
+	var (
+		nbytes    int
+		cols, err = rows.Columns()
+	)
+	if err != nil {
+		return nbytes, err
+	}
+	if !opts.NoHeader {
+		if nbytes, err = w.WriteRow(cols...); err != nil {
+			return nbytes, err
+		}
+	}
+	var (
+		values = make([]interface{}, len(cols))
+		ptrs   = make([]interface{}, len(cols))
+		fields = make([]string, len(cols))
+	)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); err != nil {
+			return nbytes, err
+		}
+		for i, v := range values {
+			if fields[i], err = w.formatSQLValue(v); err != nil {
+				return nbytes, err
+			}
+		}
+		n, err := w.WriteRow(fields...)
+		nbytes += n
+		if err != nil {
+			return nbytes, err
+		}
+	}
+	return nbytes, rows.Err()
+}
+
+//  Format a value scanned from a database/sql result as a field.
+func (c *Config) formatSQLValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return c.Null, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(c.TimeLayout), nil
+	}
+	return formatReflectValue(reflect.ValueOf(v))
+}
+
+//  Options controlling how LoadSQL and LoadSQLTx insert rows.
+type LoadOptions struct {
+	//  Maps header column names to table column names. When nil, every
+	//  column is inserted under its header name. Otherwise, only columns
+	//  present in the map are inserted.
+	Columns map[string]string
+	//  Number of rows committed per transaction by LoadSQL. When less than
+	//  one, all rows are committed in a single transaction.
+	BatchSize int
+	//  Number of rows inserted by each (multi-row) INSERT statement. When
+	//  less than one, each row is inserted separately.
+	RowsPerInsert int
+	//  Returns the placeholder for the nth (starting at 1) argument of a
+	//  statement. When nil, "?" is used for every argument.
+	Placeholder func(n int) string
+	//  Quotes the table name (each part of it, when qualified) and each
+	//  column name in statements, like QuoteIdentifier. When nil, names are used as they are, and must be
+	//  plain identifiers (see ErrorIdentifier), because column names
+	//  usually come from untrusted input.
+	QuoteIdentifier func(name string) string
+}
+
+var (
+	//  Returned when none of a header's columns are to be loaded.
+	ErrorNoColumns = errors.New("No columns to load.")
+	//  Returned when a table or column name to be used without quoting is
+	//  not of the form [A-Za-z_][A-Za-z0-9_]* (or, for tables, several
+	//  such names joined by '.').
+	ErrorIdentifier = errors.New("Invalid SQL identifier.")
+)
+
+//  Quote a name as an SQL identifier in double quotes, doubling any double
+//  quotes it contains. Can be used as a LoadOptions QuoteIdentifier.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+//  Whether name is of the form [A-Za-z_][A-Za-z0-9_]*.
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') &&
+			(i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+//  Returns the name, quoted if the options have a QuoteIdentifier. A
+//  qualified name (like "schema.table") is split on '.', and each of its
+//  parts is quoted separately.
+func (opts *LoadOptions) identifier(name string, qualified bool) (string, error) {
+	var parts = []string{name}
+	if qualified {
+		parts = strings.Split(name, ".")
+	}
+	for i, part := range parts {
+		if opts.QuoteIdentifier != nil {
+			parts[i] = opts.QuoteIdentifier(part)
+		} else if !isIdentifier(part) {
+			return "", ErrorIdentifier
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+//  Anything that can execute SQL statements, like *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+//  Insert the rows read from r into a table, committing a transaction
+//  after every opts.BatchSize rows. The Reader's Header names the columns
+//  when it has one. Otherwise, the first row read is used as the header.
+//  Fields the Reader's Config considers NULL are inserted as NULL.
+//  Returns the number of rows committed. Errors inserting rows are
+//  returned as a *LineError holding the line number of the first row of
+//  the failing statement. The batch in progress is rolled back when an
+//  error occurs.
+func LoadSQL(db *sql.DB, r *Reader, table string, opts *LoadOptions) (int, error) {
+	var (
+		tx        *sql.Tx
+		committed int
+	)
+	var l, err = newSQLLoader(r, table, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	for more := true; more; {
+		if tx, err = db.Begin(); err != nil {
+			return committed, err
+		}
+		var n int
+		if n, more, err = l.load(tx, l.opts.BatchSize); err != nil {
+			return committed, err
+		}
+		if err = tx.Commit(); err != nil {
+			return committed, err
+		}
+		tx = nil
+		committed += n
+	}
+	return committed, nil
+}
+
+//  Like LoadSQL, but all rows are inserted within the transaction tx,
+//  which is neither committed nor rolled back. Returns the number of rows
+//  inserted.
+func LoadSQLTx(tx *sql.Tx, r *Reader, table string, opts *LoadOptions) (int, error) {
+	var l, err = newSQLLoader(r, table, opts)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	n, _, err = l.load(tx, 0)
+	return n, err
+}
+
+type sqlLoader struct {
+	r       *Reader
+	opts    *LoadOptions
+	table   string   // Table name, quoted if need be.
+	columns []string // Table columns, quoted if need be.
+	index   []int    // Index of each table column in the input.
+	line    int      // Line of the first pending row.
+	pending int      // Number of rows in args.
+	args    []interface{}
+}
+
+func newSQLLoader(r *Reader, table string, opts *LoadOptions) (*sqlLoader, error) {
+	if opts == nil {
+		opts = new(LoadOptions)
+	}
+	var header, err = r.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		var row = r.ReadRow()
+		if row.Error != nil {
+			return nil, row.Error
+		}
+		header = Header(row.Fields)
+	}
+	if table, err = opts.identifier(table, true); err != nil {
+		return nil, err
+	}
+	var l = &sqlLoader{r: r, opts: opts, table: table}
+	for i, name := range header {
+		var column = name
+		if opts.Columns != nil {
+			var ok bool
+			if column, ok = opts.Columns[name]; !ok {
+				continue
+			}
+		}
+		if column, err = opts.identifier(column, false); err != nil {
+			return nil, err
+		}
+		l.columns = append(l.columns, column)
+		l.index = append(l.index, i)
+	}
+	if len(l.columns) == 0 {
+		return nil, ErrorNoColumns
+	}
+	return l, nil
+}
+
+//  Insert up to n rows (all remaining rows when n < 1). Returns the number
+//  of rows inserted and whether any input may remain.
+func (l *sqlLoader) load(db sqlExecer, n int) (int, bool, error) {
+	var (
+		inserted int
+		more     = true
+		err      error
+	)
+	for n < 1 || inserted+l.pending < n {
+		var row = l.r.ReadRow()
+		if row.HasEOF() {
+			more = false
+			break
+		}
+		if row.HasError() {
+			return inserted, more, row.Error
+		}
+		if l.pending == 0 {
+			l.line = l.r.LineNum()
+		}
+		for _, i := range l.index {
+			if i >= len(row.Fields) {
+				return inserted, more, &LineError{Line: l.r.LineNum(), Err: ErrorIndex}
+			}
+			if l.r.IsNull(row.Fields[i]) {
+				l.args = append(l.args, nil)
+			} else {
+				l.args = append(l.args, row.Fields[i])
+			}
+		}
+		if l.pending++; l.pending >= l.opts.RowsPerInsert {
+			if err = l.flush(db); err != nil {
+				return inserted, more, err
+			}
+			inserted += l.pending
+			l.pending = 0
+		}
+	}
+	if l.pending > 0 {
+		if err = l.flush(db); err != nil {
+			return inserted, more, err
+		}
+		inserted += l.pending
+		l.pending = 0
+	}
+	return inserted, more, nil
+}
+
+//  Execute an INSERT statement for the pending rows.
+func (l *sqlLoader) flush(db sqlExecer) error {
+	var (
+		q = make([]byte, 0, 64)
+		k int
+	)
+	q = append(q, "INSERT INTO "...)
+	q = append(q, l.table...)
+	q = append(q, " ("...)
+	q = append(q, strings.Join(l.columns, ", ")...)
+	q = append(q, ") VALUES "...)
+	for i := 0; i < l.pending; i++ {
+		if i > 0 {
+			q = append(q, ", "...)
+		}
+		q = append(q, '(')
+		for j := range l.columns {
+			if j > 0 {
+				q = append(q, ", "...)
+			}
+			k++
+			if l.opts.Placeholder == nil {
+				q = append(q, '?')
+			} else {
+				q = append(q, l.opts.Placeholder(k)...)
+			}
+		}
+		q = append(q, ')')
+	}
+	var _, err = db.Exec(string(q), l.args...)
+	l.args = l.args[:0]
+	if err != nil {
+		return &LineError{Line: l.line, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//  An in-memory database/sql driver. Each fakeDB is registered under a
+//  name that is used as the data source name passed to sql.Open.
+type fakeDriver struct{}
+
+type fakeDB struct {
+	mu        sync.Mutex
+	results   map[string]*fakeRows // Canned results for queries.
+	execs     []fakeExec           // Committed statements.
+	batches   [][]fakeExec         // Statements of each commit.
+	commits   int
+	rollbacks int
+	fail      string // Exec fails when an argument equals fail.
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = make(map[string]*fakeDB)
+)
+
+func init() {
+	sql.Register("csvutilfake", fakeDriver{})
+}
+
+//  Open a new, empty fake database.
+func openFakeDB(T *testing.T, name string) (*sql.DB, *fakeDB) {
+	var fdb = &fakeDB{results: make(map[string]*fakeRows)}
+	fakeDBsMu.Lock()
+	fakeDBs[name] = fdb
+	fakeDBsMu.Unlock()
+	db, err := sql.Open("csvutilfake", name)
+	if err != nil {
+		T.Fatal(err)
+	}
+	return db, fdb
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	var fdb = fakeDBs[name]
+	if fdb == nil {
+		return nil, errors.New("no fake database " + name)
+	}
+	return &fakeConn{db: fdb}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	tx []fakeExec // Uncommitted statements.
+	in bool       // In a transaction.
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c, query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.in = true
+	return c, nil
+}
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.execs = append(c.db.execs, c.tx...)
+	c.db.batches = append(c.db.batches, c.tx)
+	c.db.commits++
+	c.tx, c.in = nil, false
+	return nil
+}
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	c.db.rollbacks++
+	c.db.mu.Unlock()
+	c.tx, c.in = nil, false
+	return nil
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	var db = s.c.db
+	for _, arg := range args {
+		if str, ok := arg.(string); ok && db.fail != "" && str == db.fail {
+			return nil, errors.New("fake failure")
+		}
+	}
+	var exec = fakeExec{s.query, append([]driver.Value(nil), args...)}
+	if s.c.in {
+		s.c.tx = append(s.c.tx, exec)
+		return driver.RowsAffected(1), nil
+	}
+	db.mu.Lock()
+	db.execs = append(db.execs, exec)
+	db.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.db.mu.Lock()
+	defer s.c.db.mu.Unlock()
+	var r = s.c.db.results[s.query]
+	if r == nil {
+		return nil, errors.New("unexpected query " + s.query)
+	}
+	return &fakeRows{cols: r.cols, rows: r.rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func TestWriteSQLRows(T *testing.T) {
+	var (
+		db, fdb = openFakeDB(T, "TestWriteSQLRows")
+		when    = time.Date(2011, 7, 12, 1, 56, 3, 0, time.UTC)
+	)
+	defer db.Close()
+	fdb.results["SELECT * FROM t"] = &fakeRows{
+		cols: []string{"id", "name", "score", "ok", "seen"},
+		rows: [][]driver.Value{
+			{int64(1), []byte("alice"), 1.5, true, when},
+			{int64(2), "bob", nil, false, nil}}}
+
+	rows, err := db.Query("SELECT * FROM t")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer rows.Close()
+	var config = NewConfig()
+	config.Null = "NULL"
+	var csvw, buff = BufferWriter(config)
+	if _, err = WriteSQLRows(csvw, rows, nil); err != nil {
+		T.Fatal(err)
+	}
+	csvw.Flush()
+	var expect = "id,name,score,ok,seen\n" +
+		"1,alice,1.5,true,2011-07-12T01:56:03Z\n" +
+		"2,bob,NULL,false,NULL\n"
+	if output := buff.String(); output != expect {
+		T.Errorf("Unexpected output.\n\nExpected:\n'%s'\nReceived:\n'%s'\n\n",
+			expect, output)
+	}
+}
+
+//  Check the statements of each committed batch.
+func checkBatches(T *testing.T, fdb *fakeDB, expect [][]fakeExec) {
+	if len(fdb.batches) != len(expect) {
+		T.Fatalf("Committed %d batches (!= %d): %v", len(fdb.batches), len(expect), fdb.batches)
+	}
+	for i, batch := range expect {
+		if len(fdb.batches[i]) != len(batch) {
+			T.Errorf("Batch %d: unexpected statements %v (!= %v)", i, fdb.batches[i], batch)
+			continue
+		}
+		for j, exec := range batch {
+			var got = fdb.batches[i][j]
+			if got.query != exec.query || len(got.args) != len(exec.args) {
+				T.Errorf("Batch %d: unexpected statement %v (!= %v)", i, got, exec)
+				continue
+			}
+			for k := range exec.args {
+				if got.args[k] != exec.args[k] {
+					T.Errorf("Batch %d: unexpected arguments %v (!= %v)", i, got.args, exec.args)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestLoadSQL(T *testing.T) {
+	var db, fdb = openFakeDB(T, "TestLoadSQL")
+	defer db.Close()
+	var config = NewConfig()
+	config.HasHeader = true
+	config.Null = "NULL"
+	var (
+		csvr = StringReader("id,name,junk\n1,alice,x\n2,NULL,y\n3,chris,z\n", config)
+		opts = &LoadOptions{
+			Columns:       map[string]string{"id": "user_id", "name": "name"},
+			BatchSize:     2,
+			RowsPerInsert: 2}
+	)
+	n, err := LoadSQL(db, csvr, "users", opts)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if n != 3 {
+		T.Errorf("Loaded %d rows (!= 3)", n)
+	}
+	checkBatches(T, fdb, [][]fakeExec{
+		{{"INSERT INTO users (user_id, name) VALUES (?, ?), (?, ?)",
+			[]driver.Value{"1", "alice", "2", nil}}},
+		{{"INSERT INTO users (user_id, name) VALUES (?, ?)",
+			[]driver.Value{"3", "chris"}}}})
+	if fdb.rollbacks != 0 {
+		T.Errorf("Rolled back %d times", fdb.rollbacks)
+	}
+}
+
+func TestLoadSQLPlaceholder(T *testing.T) {
+	var db, fdb = openFakeDB(T, "TestLoadSQLPlaceholder")
+	defer db.Close()
+	var (
+		csvr = StringReader("id,name\n1,a\n2,b\n3,c\n", nil)
+		opts = &LoadOptions{
+			BatchSize:       0,
+			RowsPerInsert:   2,
+			Placeholder:     func(n int) string { return "$" + strconv.Itoa(n) },
+			QuoteIdentifier: QuoteIdentifier}
+	)
+	if n, err := LoadSQL(db, csvr, "app.users", opts); err != nil || n != 3 {
+		T.Fatalf("Loaded %d rows, %v", n, err)
+	}
+	checkBatches(T, fdb, [][]fakeExec{{
+		{`INSERT INTO "app"."users" ("id", "name") VALUES ($1, $2), ($3, $4)`,
+			[]driver.Value{"1", "a", "2", "b"}},
+		{`INSERT INTO "app"."users" ("id", "name") VALUES ($1, $2)`,
+			[]driver.Value{"3", "c"}}}})
+}
+
+func TestLoadSQLIdentifiers(T *testing.T) {
+	var db, fdb = openFakeDB(T, "TestLoadSQLIdentifiers")
+	defer db.Close()
+	var hostile = "a) VALUES (1); DROP TABLE t; --"
+	for _, test := range []struct{ table, header string }{
+		{"t", "id," + hostile},
+		{"t", "id,1a"},
+		{"t; DROP TABLE t", "id"},
+		{"app.", "id"},
+	} {
+		var csvr = StringReader(test.header+"\n1,2\n", nil)
+		if _, err := LoadSQL(db, csvr, test.table, nil); err != ErrorIdentifier {
+			T.Errorf("%q %q: unexpected error %v", test.table, test.header, err)
+		}
+	}
+	if len(fdb.execs) != 0 {
+		T.Errorf("Executed statements %v", fdb.execs)
+	}
+	// A qualified table name is fine, but not a hostile column name.
+	var csvr = StringReader("id,"+hostile+"\n1,2\n", nil)
+	if _, err := LoadSQL(db, csvr, "app.t", nil); err != ErrorIdentifier {
+		T.Errorf("Unexpected error %v", err)
+	}
+	var opts = &LoadOptions{QuoteIdentifier: QuoteIdentifier}
+	csvr = StringReader("id,\"x\",y.z\n1,2,3\n", nil)
+	if _, err := LoadSQL(db, csvr, "app.t", opts); err != nil {
+		T.Fatal(err)
+	}
+	checkBatches(T, fdb, [][]fakeExec{{
+		{`INSERT INTO "app"."t" ("id", """x""", "y.z") VALUES (?, ?, ?)`, []driver.Value{"1", "2", "3"}}}})
+}
+
+func TestLoadSQLError(T *testing.T) {
+	var db, fdb = openFakeDB(T, "TestLoadSQLError")
+	defer db.Close()
+	fdb.fail = "bad"
+	var (
+		csvr = StringReader("id,name\n1,alice\n2,bob\n3,carol\n4,bad\n", nil)
+		opts = &LoadOptions{BatchSize: 2}
+	)
+	n, err := LoadSQL(db, csvr, "users", opts)
+	if n != 2 {
+		T.Errorf("Committed %d rows (!= 2)", n)
+	}
+	lerr, ok := err.(*LineError)
+	if !ok {
+		T.Fatalf("Unexpected error %v", err)
+	}
+	if lerr.Line != 5 {
+		T.Errorf("Error on line %d (!= 5)", lerr.Line)
+	}
+	// Row 3 was inserted in the failed batch, which was rolled back.
+	checkBatches(T, fdb, [][]fakeExec{{
+		{"INSERT INTO users (id, name) VALUES (?, ?)", []driver.Value{"1", "alice"}},
+		{"INSERT INTO users (id, name) VALUES (?, ?)", []driver.Value{"2", "bob"}}}})
+	if fdb.rollbacks != 1 {
+		T.Errorf("Rolled back %d times (!= 1)", fdb.rollbacks)
+	}
+}
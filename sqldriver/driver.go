@@ -0,0 +1,351 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//  Package sqldriver is a read-only database/sql driver that serves each
+//  CSV file in a directory as a table. Importing it registers the driver
+//  as "csv".
+//
+//      db, err := sql.Open("csv", "/path/to/exports")
+//      rows, err := db.Query("SELECT name, age FROM people WHERE age > ? ORDER BY age DESC LIMIT 10", 30)
+//
+//  The table named t is read from the file t.csv, whose first row must be
+//  a header of column names. The data source name may end with options,
+//  as in "/path/to/exports?sep=%09&null=NA&comments=true", which set the
+//  separator, NULL token and comment handling of the csvutil.Config used
+//  to read tables.
+//
+//  Statements are limited to
+//
+//      SELECT * | column [AS alias], ...
+//      FROM table
+//      [WHERE condition]
+//      [ORDER BY column [ASC|DESC], ...]
+//      [LIMIT n]
+//
+//  where conditions combine comparisons (=, !=, <>, <, <=, >, >=) and IS
+//  [NOT] NULL tests with AND, OR, NOT and parentheses. Operands are
+//  columns, 'strings', numbers, NULL and ? placeholders. Fields that both
+//  look like numbers are compared numerically, others are compared as
+//  strings. All non-NULL values are returned as strings.
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bmatsuo/csvutil"
+)
+
+var (
+	ErrorReadOnly     = errors.New("CSV tables are read-only.")
+	ErrorTransactions = errors.New("Transactions are not supported.")
+)
+
+func init() {
+	sql.Register("csv", &Driver{})
+}
+
+//  The CSV driver. Names given to Open are directories of CSV files.
+type Driver struct{}
+
+//  Open a connection to a directory of CSV files.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	var (
+		c      = &conn{config: csvutil.NewConfig()}
+		dir    = name
+		params string
+	)
+	if i := strings.LastIndex(name, "?"); i >= 0 {
+		dir, params = name[:i], name[i+1:]
+	}
+	var opts, err = url.ParseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	for key, vals := range opts {
+		var val = vals[len(vals)-1]
+		switch key {
+		case "sep":
+			var r, n = utf8.DecodeRuneInString(val)
+			if n == 0 || n != len(val) {
+				return nil, fmt.Errorf("bad separator %q", val)
+			}
+			c.config.Sep = r
+		case "null":
+			c.config.Null = val
+		case "comments":
+			if c.config.Comments, err = strconv.ParseBool(val); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	c.config.HasHeader = true
+	var info os.FileInfo
+	if info, err = os.Stat(dir); err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	c.dir = dir
+	return c, nil
+}
+
+type conn struct {
+	dir    string
+	config *csvutil.Config
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	var q, err = parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{c, q}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return nil, ErrorTransactions }
+
+type stmt struct {
+	c *conn
+	q *query
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return s.q.nargs }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, ErrorReadOnly
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	var (
+		q = s.q
+		e = &env{isNull: s.c.config.IsNull}
+	)
+	for _, arg := range args {
+		var v, err = argValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		e.args = append(e.args, v)
+	}
+	if strings.ContainsAny(q.table, `/\`) || q.table == ".." {
+		return nil, fmt.Errorf("bad table name %q", q.table)
+	}
+	var f, err = os.Open(filepath.Join(s.c.dir, q.table+".csv"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no such table %s", q.table)
+		}
+		return nil, err
+	}
+	var (
+		r      = &rows{f: f, csvr: csvutil.NewReader(f, s.c.config), q: q, e: e}
+		header csvutil.Header
+	)
+	if header, err = r.csvr.Header(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = r.bind(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if q.orderBy != nil {
+		if err = r.sort(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+//  Convert a placeholder argument to a field value.
+func argValue(arg driver.Value) (value, error) {
+	switch v := arg.(type) {
+	case nil:
+		return value{null: true}, nil
+	case string:
+		return value{s: v}, nil
+	case []byte:
+		return value{s: string(v)}, nil
+	case int64:
+		return value{s: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return value{s: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case bool:
+		return value{s: strconv.FormatBool(v)}, nil
+	case time.Time:
+		return value{s: v.Format(csvutil.DefaultConfig.TimeLayout)}, nil
+	}
+	return value{}, fmt.Errorf("unsupported argument type %T", arg)
+}
+
+type rows struct {
+	f       *os.File
+	csvr    *csvutil.Reader
+	q       *query
+	e       *env
+	names   []string // Result column names.
+	project []int    // Input index of each result column.
+	limit   int      // Maximum rows to return (-1 for no limit).
+	sorted  [][]string
+	sortedi int
+	n       int // Rows returned.
+}
+
+//  Resolve column names against the table header.
+func (r *rows) bind(header csvutil.Header) error {
+	var err error
+	r.e.index = make(map[string]int, len(header))
+	for i := len(header) - 1; i >= 0; i-- {
+		r.e.index[header[i]] = i
+	}
+	var check = func(name string) {
+		if _, ok := r.e.index[name]; !ok && err == nil {
+			err = fmt.Errorf("no such column %s in table %s", name, r.q.table)
+		}
+	}
+	if r.q.columns == nil {
+		r.names = header
+		for i := range header {
+			r.project = append(r.project, i)
+		}
+	}
+	for _, c := range r.q.columns {
+		check(c.name)
+		r.names = append(r.names, c.alias)
+		r.project = append(r.project, r.e.index[c.name])
+	}
+	if r.q.where != nil {
+		r.q.where.eachColumn(check)
+	}
+	for _, o := range r.q.orderBy {
+		check(o.column)
+	}
+	r.limit = -1
+	if r.q.limit != nil {
+		var v = r.q.limit.value(r.e)
+		var n, errn = strconv.Atoi(v.s)
+		if v.null || errn != nil || n < 0 {
+			return fmt.Errorf("bad LIMIT %q", v.s)
+		}
+		r.limit = n
+	}
+	return err
+}
+
+//  Read the next row satisfying the WHERE clause.
+func (r *rows) scan() ([]string, error) {
+	for {
+		var row = r.csvr.ReadRow()
+		if row.HasError() {
+			return nil, row.Error
+		}
+		if len(row.Fields) == 0 {
+			continue
+		}
+		r.e.fields = row.Fields
+		if r.q.where == nil || r.q.where.test(r.e) {
+			return row.Fields, nil
+		}
+	}
+}
+
+//  Read and sort all rows satisfying the WHERE clause.
+func (r *rows) sort() error {
+	for {
+		var fields, err = r.scan()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		r.sorted = append(r.sorted, fields)
+	}
+	var keys = make([]int, len(r.q.orderBy))
+	for i, o := range r.q.orderBy {
+		keys[i] = r.e.index[o.column]
+	}
+	var field = func(row []string, i int) value {
+		if i >= len(row) || r.e.isNull(row[i]) {
+			return value{null: true}
+		}
+		return value{s: row[i]}
+	}
+	sort.SliceStable(r.sorted, func(a, b int) bool {
+		for k, o := range r.q.orderBy {
+			var (
+				x, y = field(r.sorted[a], keys[k]), field(r.sorted[b], keys[k])
+				cmp  int
+			)
+			// NULLs sort first.
+			switch {
+			case x.null && y.null:
+			case x.null:
+				cmp = -1
+			case y.null:
+				cmp = 1
+			default:
+				cmp = compare(x.s, y.s)
+			}
+			if o.desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func (r *rows) Columns() []string { return r.names }
+
+func (r *rows) Close() error { return r.f.Close() }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.limit >= 0 && r.n >= r.limit {
+		return io.EOF
+	}
+	var (
+		fields []string
+		err    error
+	)
+	if r.q.orderBy != nil {
+		if r.sortedi >= len(r.sorted) {
+			return io.EOF
+		}
+		fields = r.sorted[r.sortedi]
+		r.sortedi++
+	} else if fields, err = r.scan(); err != nil {
+		return err
+	}
+	r.n++
+	for i, j := range r.project {
+		if j >= len(fields) || r.e.isNull(fields[j]) {
+			dest[i] = nil
+		} else {
+			dest[i] = fields[j]
+		}
+	}
+	return nil
+}
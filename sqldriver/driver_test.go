@@ -0,0 +1,137 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldriver
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPeople = `name,age,country
+alice,34,US
+bob,27,CA
+chris,,US
+dana,41,MX
+erin,9,US
+`
+
+var testScores = `name,score,café
+ann,5,é
+bob,NaN,x
+zoë,1e-5,y
+`
+
+func openTestDB(T *testing.T) (*sql.DB, func()) {
+	var dir, err = ioutil.TempDir("", "csvutil-sqldriver")
+	if err != nil {
+		T.Fatal(err)
+	}
+	var cleanup = func() { os.RemoveAll(dir) }
+	err = ioutil.WriteFile(filepath.Join(dir, "people.csv"), []byte(testPeople), 0600)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(dir, "scores.csv"), []byte(testScores), 0600)
+	}
+	if err != nil {
+		cleanup()
+		T.Fatal(err)
+	}
+	db, err := sql.Open("csv", dir)
+	if err != nil {
+		cleanup()
+		T.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		cleanup()
+	}
+}
+
+//  Run a query and join its results as "a,b;c,d" (NULL as "-").
+func queryString(T *testing.T, db *sql.DB, query string, args ...interface{}) string {
+	var rows, err = db.Query(query, args...)
+	if err != nil {
+		T.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var cols []string
+	if cols, err = rows.Columns(); err != nil {
+		T.Fatal(err)
+	}
+	var (
+		out  []string
+		vals = make([]sql.NullString, len(cols))
+		ptrs = make([]interface{}, len(cols))
+	)
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); err != nil {
+			T.Fatal(err)
+		}
+		var fields = make([]string, len(vals))
+		for i, v := range vals {
+			if fields[i] = v.String; !v.Valid {
+				fields[i] = "-"
+			}
+		}
+		out = append(out, strings.Join(fields, ","))
+	}
+	if err = rows.Err(); err != nil {
+		T.Fatal(err)
+	}
+	return strings.Join(out, ";")
+}
+
+func TestQuery(T *testing.T) {
+	var db, cleanup = openTestDB(T)
+	defer cleanup()
+	var tests = []struct {
+		query  string
+		args   []interface{}
+		expect string
+	}{
+		{"SELECT * FROM people LIMIT 2", nil, "alice,34,US;bob,27,CA"},
+		{"SELECT name FROM people WHERE age > 30", nil, "alice;dana"},
+		{"SELECT name FROM people WHERE age > ? AND country = 'US'", []interface{}{10}, "alice"},
+		{"SELECT name FROM people WHERE country = 'CA' OR (age < 10 AND NOT country <> 'US')", nil, "bob;erin"},
+		{"SELECT name, age FROM people WHERE age IS NULL", nil, "chris,-"},
+		{"SELECT name AS n FROM people ORDER BY age DESC LIMIT 3", nil, "dana;alice;bob"},
+		{"SELECT name FROM people ORDER BY country, name DESC", nil, "bob;dana;erin;chris;alice"},
+		{"SELECT name FROM scores WHERE score = 5", nil, "ann"},
+		{"SELECT name FROM scores WHERE score = 1e-5 OR score = ?", []interface{}{"NaN"}, "bob;zoë"},
+		{"SELECT name FROM scores WHERE score < 1", nil, "zoë"},
+		{"SELECT café, \"café\" AS c FROM scores WHERE name = 'zoë'", nil, "y,y"},
+	}
+	for _, test := range tests {
+		if out := queryString(T, db, test.query, test.args...); out != test.expect {
+			T.Errorf("%s: got %q (!= %q)", test.query, out, test.expect)
+		}
+	}
+}
+
+func TestQueryErrors(T *testing.T) {
+	var db, cleanup = openTestDB(T)
+	defer cleanup()
+	for _, query := range []string{
+		"SELECT FROM people",
+		"SELECT name FROM people WHERE",
+		"SELECT height FROM people",
+		"SELECT name FROM nobody",
+		"SELECT name FROM people WHERE name = 'alice",
+	} {
+		if rows, err := db.Query(query); err == nil {
+			rows.Close()
+			T.Errorf("%s: no error", query)
+		}
+	}
+	if _, err := db.Exec("SELECT name FROM people"); err != ErrorReadOnly {
+		T.Errorf("Unexpected Exec error %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldriver
+
+/*
+*  File: eval.go
+*  Description: Evaluation of WHERE clauses against CSV rows.
+ */
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+//  A field value, which may be NULL.
+type value struct {
+	s    string
+	null bool
+}
+
+//  The row a condition is evaluated against.
+type env struct {
+	index  map[string]int // Column indices by name.
+	fields []string
+	args   []value
+	isNull func(string) bool
+}
+
+type operand interface {
+	value(e *env) value
+	eachColumn(f func(string))
+}
+
+type cond interface {
+	test(e *env) bool
+	eachColumn(f func(string))
+}
+
+type literal string
+type nullLiteral struct{}
+type placeholder int
+type columnRef string
+
+func (l literal) value(e *env) value            { return value{s: string(l)} }
+func (l literal) eachColumn(f func(string))     {}
+func (nullLiteral) value(e *env) value          { return value{null: true} }
+func (nullLiteral) eachColumn(f func(string))   {}
+func (p placeholder) value(e *env) value        { return e.args[p] }
+func (p placeholder) eachColumn(f func(string)) {}
+
+func (c columnRef) value(e *env) value {
+	var i = e.index[string(c)]
+	if i >= len(e.fields) || e.isNull(e.fields[i]) {
+		return value{null: true}
+	}
+	return value{s: e.fields[i]}
+}
+func (c columnRef) eachColumn(f func(string)) { f(string(c)) }
+
+type andCond struct{ left, right cond }
+type orCond struct{ left, right cond }
+type notCond struct{ c cond }
+type nullCond struct{ x operand }
+type compareCond struct {
+	op          string
+	left, right operand
+}
+
+func (c andCond) test(e *env) bool { return c.left.test(e) && c.right.test(e) }
+func (c andCond) eachColumn(f func(string)) {
+	c.left.eachColumn(f)
+	c.right.eachColumn(f)
+}
+func (c orCond) test(e *env) bool { return c.left.test(e) || c.right.test(e) }
+func (c orCond) eachColumn(f func(string)) {
+	c.left.eachColumn(f)
+	c.right.eachColumn(f)
+}
+func (c notCond) test(e *env) bool           { return !c.c.test(e) }
+func (c notCond) eachColumn(f func(string))  { c.c.eachColumn(f) }
+func (c nullCond) test(e *env) bool          { return c.x.value(e).null }
+func (c nullCond) eachColumn(f func(string)) { c.x.eachColumn(f) }
+
+//  Comparisons involving NULL are false.
+func (c compareCond) test(e *env) bool {
+	var x, y = c.left.value(e), c.right.value(e)
+	if x.null || y.null {
+		return false
+	}
+	var cmp = compare(x.s, y.s)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+func (c compareCond) eachColumn(f func(string)) {
+	c.left.eachColumn(f)
+	c.right.eachColumn(f)
+}
+
+//  Compare two fields numerically if both are (finite) numbers, and as
+//  strings otherwise. NaN and infinities compare as strings, so "NaN" is
+//  never equal to a number.
+func compare(a, b string) int {
+	if x, ok := parseNumber(a); ok {
+		if y, ok := parseNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+//  Parse a finite number.
+func parseNumber(s string) (float64, bool) {
+	var x, err = strconv.ParseFloat(s, 64)
+	return x, err == nil && !math.IsNaN(x) && !math.IsInf(x, 0)
+}
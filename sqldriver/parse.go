@@ -0,0 +1,374 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldriver
+
+/*
+*  File: parse.go
+*  Description: Parsing of the supported subset of SELECT statements.
+ */
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//  A parsed SELECT statement.
+type query struct {
+	columns []column // Projected columns (nil for *).
+	table   string
+	where   cond // May be nil.
+	orderBy []order
+	limit   operand // May be nil.
+	nargs   int     // Number of placeholders.
+}
+
+type column struct {
+	name, alias string
+}
+
+type order struct {
+	column string
+	desc   bool
+}
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp // Operators and punctuation.
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+//  Split a statement into tokens.
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		var c, size = utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c == '_' || unicode.IsLetter(c):
+			var j = i
+			for j < len(s) {
+				var d, n = utf8.DecodeRuneInString(s[j:])
+				if d != '_' && !unicode.IsLetter(d) && !unicode.IsDigit(d) {
+					break
+				}
+				j += n
+			}
+			toks = append(toks, token{tokIdent, s[i:j], i})
+			i = j
+		case c == '-' || c == '.' || '0' <= c && c <= '9':
+			var j = lexNumber(s, i)
+			toks = append(toks, token{tokNumber, s[i:j], i})
+			i = j
+		case c == '\'' || c == '"':
+			// Quoted strings and identifiers. Doubled quotes escape.
+			var (
+				text []byte
+				j    = i + 1
+			)
+			for ; ; j++ {
+				if j >= len(s) {
+					return nil, fmt.Errorf("unterminated quote at %d", i)
+				}
+				if s[j] == byte(c) {
+					if j+1 < len(s) && s[j+1] == byte(c) {
+						j++
+					} else {
+						break
+					}
+				}
+				// Quotes are ASCII, so the bytes of other runes are copied
+				// intact.
+				text = append(text, s[j])
+			}
+			var kind = tokString
+			if c == '"' {
+				kind = tokIdent
+			}
+			toks = append(toks, token{kind, string(text), i})
+			i = j + 1
+		default:
+			var op = s[i : i+size]
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "<=", ">=", "<>", "!=":
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!(),*?;", op[:1]) || op == "!" || size > 1 {
+				return nil, fmt.Errorf("unexpected %q at %d", op, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+//  The end of the number starting at s[i]: an optional '-', digits with an
+//  optional decimal point, and an optional exponent like e-5.
+func lexNumber(s string, i int) int {
+	var j = i + 1
+	for j < len(s) {
+		switch c := s[j]; {
+		case c == '.' || '0' <= c && c <= '9':
+		case (c == 'e' || c == 'E') && j+1 < len(s):
+			if (s[j+1] == '-' || s[j+1] == '+') && j+2 < len(s) {
+				j++
+			}
+		default:
+			return j
+		}
+		j++
+	}
+	return j
+}
+
+type parser struct {
+	toks  []token
+	i     int
+	nargs int
+}
+
+//  Parse a SELECT statement.
+func parse(s string) (*query, error) {
+	var toks, err = lex(s)
+	if err != nil {
+		return nil, err
+	}
+	var p = &parser{toks: toks}
+	var q *query
+	if q, err = p.parseSelect(); err != nil {
+		return nil, err
+	}
+	q.nargs = p.nargs
+	return q, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	var t = p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+//  Consume the next token if it is the given keyword or operator.
+func (p *parser) accept(text string) bool {
+	var t = p.peek()
+	if (t.kind == tokIdent || t.kind == tokOp) && strings.EqualFold(t.text, text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %s", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	var t = p.peek()
+	var near = t.text
+	if t.kind == tokEOF {
+		near = "end of statement"
+	}
+	return fmt.Errorf("syntax error at %d near %q: %s", t.pos, near, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) ident() (string, error) {
+	var t = p.peek()
+	if t.kind != tokIdent || isKeyword(t.text) {
+		return "", p.errorf("expected a name")
+	}
+	p.i++
+	return t.text, nil
+}
+
+func isKeyword(s string) bool {
+	switch strings.ToUpper(s) {
+	case "SELECT", "FROM", "WHERE", "ORDER", "BY", "LIMIT", "AND", "OR",
+		"NOT", "IS", "NULL", "AS", "ASC", "DESC":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseSelect() (*query, error) {
+	var (
+		q   = new(query)
+		err error
+	)
+	if err = p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	if !p.accept("*") {
+		for {
+			var c column
+			if c.name, err = p.ident(); err != nil {
+				return nil, err
+			}
+			c.alias = c.name
+			if p.accept("AS") {
+				if c.alias, err = p.ident(); err != nil {
+					return nil, err
+				}
+			}
+			q.columns = append(q.columns, c)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err = p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if q.table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		if q.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("ORDER") {
+		if err = p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			var o order
+			if o.column, err = p.ident(); err != nil {
+				return nil, err
+			}
+			if p.accept("DESC") {
+				o.desc = true
+			} else {
+				p.accept("ASC")
+			}
+			q.orderBy = append(q.orderBy, o)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") {
+		if q.limit, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if _, ok := q.limit.(columnRef); ok {
+			return nil, fmt.Errorf("LIMIT must be a number")
+		}
+	}
+	p.accept(";")
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected input")
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (cond, error) {
+	var left, err = p.parseAnd()
+	for err == nil && p.accept("OR") {
+		var right cond
+		if right, err = p.parseAnd(); err == nil {
+			left = orCond{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (cond, error) {
+	var left, err = p.parseNot()
+	for err == nil && p.accept("AND") {
+		var right cond
+		if right, err = p.parseNot(); err == nil {
+			left = andCond{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseNot() (cond, error) {
+	if p.accept("NOT") {
+		var c, err = p.parseNot()
+		return notCond{c}, err
+	}
+	if p.accept("(") {
+		var c, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	var left, err = p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("IS") {
+		var not = p.accept("NOT")
+		if err = p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		var c cond = nullCond{left}
+		if not {
+			c = notCond{c}
+		}
+		return c, nil
+	}
+	var op = p.next()
+	switch op.text {
+	case "=", "!=", "<>", "<", "<=", ">", ">=":
+	default:
+		p.i--
+		return nil, p.errorf("expected a comparison")
+	}
+	var right operand
+	if right, err = p.parseOperand(); err != nil {
+		return nil, err
+	}
+	return compareCond{op.text, left, right}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	var t = p.peek()
+	switch {
+	case t.kind == tokString:
+		p.i++
+		return literal(t.text), nil
+	case t.kind == tokNumber:
+		if _, err := strconv.ParseFloat(t.text, 64); err != nil {
+			return nil, p.errorf("bad number")
+		}
+		p.i++
+		return literal(t.text), nil
+	case t.kind == tokOp && t.text == "?":
+		p.i++
+		p.nargs++
+		return placeholder(p.nargs - 1), nil
+	case t.kind == tokIdent && strings.EqualFold(t.text, "NULL"):
+		p.i++
+		return nullLiteral{}, nil
+	}
+	var name, err = p.ident()
+	if err != nil {
+		return nil, err
+	}
+	return columnRef(name), nil
+}
@@ -0,0 +1,455 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: tableschema.go
+*  Description: Frictionless Table Schema and Data Package descriptors.
+ */
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//  A Frictionless Table Schema descriptor.
+type TableSchema struct {
+	Fields        []TableField `json:"fields"`
+	PrimaryKey    interface{}  `json:"primaryKey,omitempty"` // A string or []string.
+	MissingValues []string     `json:"missingValues,omitempty"`
+}
+
+//  A field of a Table Schema.
+type TableField struct {
+	Name        string            `json:"name"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	Constraints *TableConstraints `json:"constraints,omitempty"`
+}
+
+//  The constraints of a Table Schema field. Enum values, minimums and
+//  maximums are numbers or strings.
+type TableConstraints struct {
+	Required  bool          `json:"required,omitempty"`
+	Unique    bool          `json:"unique,omitempty"`
+	Enum      []interface{} `json:"enum,omitempty"`
+	Minimum   interface{}   `json:"minimum,omitempty"`
+	Maximum   interface{}   `json:"maximum,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	MaxLength int           `json:"maxLength,omitempty"`
+}
+
+//  A Frictionless CSV Dialect descriptor.
+type TableDialect struct {
+	Delimiter        string `json:"delimiter,omitempty"`
+	QuoteChar        string `json:"quoteChar,omitempty"`
+	Header           *bool  `json:"header,omitempty"`
+	CommentChar      string `json:"commentChar,omitempty"`
+	SkipInitialSpace bool   `json:"skipInitialSpace,omitempty"`
+}
+
+//  A tabular resource of a Data Package. Only inline schemas and dialects
+//  are supported, not paths to other descriptors.
+type DataResource struct {
+	Name    string        `json:"name"`
+	Path    string        `json:"path"`
+	Format  string        `json:"format,omitempty"`
+	Schema  *TableSchema  `json:"schema,omitempty"`
+	Dialect *TableDialect `json:"dialect,omitempty"`
+}
+
+//  A Frictionless Data Package descriptor (datapackage.json).
+type DataPackage struct {
+	Name      string         `json:"name,omitempty"`
+	Resources []DataResource `json:"resources"`
+}
+
+//  Returned for descriptor properties that can not be honored.
+type UnsupportedError struct {
+	Property string
+	Value    string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("Unsupported %s %q.", e.Property, e.Value)
+}
+
+//  Read a Data Package descriptor.
+func ReadDataPackage(r io.Reader) (*DataPackage, error) {
+	var p = new(DataPackage)
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+//  Read a Table Schema descriptor.
+func ReadTableSchema(r io.Reader) (*TableSchema, error) {
+	var t = new(TableSchema)
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+//  Write the descriptor as indented JSON.
+func (p *DataPackage) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, p)
+}
+
+//  Write the descriptor as indented JSON.
+func (t *TableSchema) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, t)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	var data, err = json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+//  Returns the named resource, or nil.
+func (p *DataPackage) Resource(name string) *DataResource {
+	for i := range p.Resources {
+		if p.Resources[i].Name == name {
+			return &p.Resources[i]
+		}
+	}
+	return nil
+}
+
+//  Describe a CSV file written with the given schema and Config.
+func NewDataResource(name, path string, s *Schema, c *Config) DataResource {
+	var header = s.HasHeader
+	var d = &TableDialect{Delimiter: string(c.Sep), Header: &header}
+	if c.Comments {
+		d.CommentChar = c.CommentPrefix
+	}
+	return DataResource{Name: name, Path: path, Format: "csv", Schema: NewTableSchema(s), Dialect: d}
+}
+
+//  A Config for reading the resource, set by its dialect and schema.
+func (res *DataResource) Config() (*Config, error) {
+	var c = NewConfig()
+	c.HasHeader = true
+	if res.Dialect != nil {
+		if err := res.Dialect.Configure(c); err != nil {
+			return nil, err
+		}
+	}
+	if res.Schema != nil {
+		var null, err = res.Schema.null()
+		if err != nil {
+			return nil, err
+		}
+		c.Null = null
+	}
+	return c, nil
+}
+
+//  Configure a Config to read CSV data in the dialect. The Reader does not
+//  interpret quotes, so any quote character other than the default '"' is
+//  reported as an *UnsupportedError (and quoted fields are not unquoted).
+//  SkipInitialSpace sets Trim, which also trims trailing space.
+func (d *TableDialect) Configure(c *Config) error {
+	if d.Delimiter != "" {
+		var sep, n = utf8.DecodeRuneInString(d.Delimiter)
+		if n != len(d.Delimiter) {
+			return &UnsupportedError{"delimiter", d.Delimiter}
+		}
+		c.Sep = sep
+	}
+	if d.QuoteChar != "" && d.QuoteChar != `"` {
+		return &UnsupportedError{"quoteChar", d.QuoteChar}
+	}
+	if d.Header != nil {
+		c.HasHeader = *d.Header
+	}
+	if d.CommentChar != "" {
+		c.Comments = true
+		c.CommentPrefix = d.CommentChar
+	}
+	if d.SkipInitialSpace {
+		c.Trim = true
+	}
+	return nil
+}
+
+//  The NULL token of the schema's missing values.
+func (t *TableSchema) null() (string, error) {
+	return singleNull("missingValues", t.MissingValues)
+}
+
+//  The one NULL token among values other than the empty string (which is
+//  always NULL), as a Config supports only one.
+func singleNull(property string, values []string) (string, error) {
+	var null string
+	for _, v := range values {
+		if v == "" || v == null {
+			continue
+		}
+		if null != "" {
+			return "", &UnsupportedError{property, v}
+		}
+		null = v
+	}
+	return null, nil
+}
+
+//  The columns of a primary key given as a JSON string or array.
+func primaryKeyNames(v interface{}) ([]string, error) {
+	switch pk := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{pk}, nil
+	case []string:
+		return pk, nil
+	case []interface{}:
+		var names []string
+		for _, v := range pk {
+			var name, ok = v.(string)
+			if !ok {
+				return nil, &UnsupportedError{"primaryKey", fmt.Sprint(v)}
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+	return nil, &UnsupportedError{"primaryKey", fmt.Sprint(v)}
+}
+
+//  Convert the descriptor to a Schema, of CSV data with a header. Types
+//  without an equivalent FieldType (such as object, array and geopoint)
+//  are strings, and years are integers. Date, time and datetime formats
+//  may be "default" or strptime patterns using the directives %Y, %y, %m,
+//  %d, %H, %I, %M, %S, %p, %b, %B, %z, %Z and %%.
+func (t *TableSchema) Schema() (*Schema, error) {
+	var (
+		s   = &Schema{HasHeader: true}
+		err error
+	)
+	if s.Null, err = t.null(); err != nil {
+		return nil, err
+	}
+	if s.PrimaryKey, err = primaryKeyNames(t.PrimaryKey); err != nil {
+		return nil, err
+	}
+	for _, f := range t.Fields {
+		var col = SchemaColumn{Name: f.Name}
+		if col.Type, col.Layout, err = tableFieldType(f.Type, f.Format); err != nil {
+			return nil, err
+		}
+		if c := f.Constraints; c != nil {
+			col.Required = c.Required
+			col.Unique = c.Unique
+			col.Pattern = c.Pattern
+			col.MaxLength = c.MaxLength
+			for _, v := range c.Enum {
+				col.Values = append(col.Values, fmt.Sprint(v))
+			}
+			if col.Minimum, err = tableBound("minimum", c.Minimum); err != nil {
+				return nil, err
+			}
+			if col.Maximum, err = tableBound("maximum", c.Maximum); err != nil {
+				return nil, err
+			}
+		}
+		s.Columns = append(s.Columns, col)
+	}
+	return s, nil
+}
+
+//  A numeric bound of a constraint.
+func tableBound(property string, v interface{}) (*float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &v, nil
+	case string:
+		if x, err := strconv.ParseFloat(v, 64); err == nil {
+			return &x, nil
+		}
+	}
+	return nil, &UnsupportedError{property, fmt.Sprint(v)}
+}
+
+//  The FieldType and time layout of a Table Schema type and format.
+func tableFieldType(typ, format string) (FieldType, string, error) {
+	var layout string
+	switch typ {
+	case "integer", "year":
+		return TypeInteger, "", nil
+	case "number":
+		return TypeNumber, "", nil
+	case "boolean":
+		return TypeBoolean, "", nil
+	case "date":
+		layout = "2006-01-02"
+	case "time":
+		layout = "15:04:05"
+	case "datetime":
+		layout = time.RFC3339
+	default:
+		return TypeString, "", nil
+	}
+	switch format {
+	case "", "default":
+		return TypeDatetime, layout, nil
+	case "any":
+		return TypeString, "", nil
+	}
+	var err error
+	if layout, err = strptimeLayout(format); err != nil {
+		return 0, "", err
+	}
+	return TypeDatetime, layout, nil
+}
+
+//  Pairs of strptime directives and time layout elements.
+var strptimeDirectives = []string{
+	"%Y", "2006", "%y", "06", "%m", "01", "%d", "02",
+	"%H", "15", "%I", "03", "%M", "04", "%S", "05", "%p", "PM",
+	"%B", "January", "%b", "Jan", "%z", "-0700", "%Z", "MST",
+}
+
+//  Convert a strptime pattern to a time layout.
+func strptimeLayout(format string) (string, error) {
+	var layout []string
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout = append(layout, format[i:i+1])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", &UnsupportedError{"format", format}
+		}
+		var directive, elem = format[i : i+2], ""
+		if directive == "%%" {
+			elem = "%"
+		}
+		for j := 0; j < len(strptimeDirectives); j += 2 {
+			if strptimeDirectives[j] == directive {
+				elem = strptimeDirectives[j+1]
+			}
+		}
+		if elem == "" {
+			return "", &UnsupportedError{"format", format}
+		}
+		layout = append(layout, elem)
+		i++
+	}
+	return strings.Join(layout, ""), nil
+}
+
+//  Convert a time layout to a strptime pattern, or return false if it has
+//  elements without a directive.
+func layoutStrptime(layout string) (string, bool) {
+	var format []string
+	for i := 0; i < len(layout); {
+		var matched bool
+		// Longer elements come first.
+		for _, elem := range []string{"January", "-0700", "2006", "Jan", "MST",
+			"01", "02", "03", "04", "05", "06", "15", "PM"} {
+			if strings.HasPrefix(layout[i:], elem) {
+				for j := 1; j < len(strptimeDirectives); j += 2 {
+					if strptimeDirectives[j] == elem {
+						format = append(format, strptimeDirectives[j-1])
+					}
+				}
+				i += len(elem)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		var c = layout[i : i+1]
+		if strings.ContainsAny(c, "0123456789") {
+			return "", false // An element without a directive, like "_2".
+		}
+		if c == "%" {
+			c = "%%"
+		}
+		format = append(format, c)
+		i++
+	}
+	return strings.Join(format, ""), true
+}
+
+//  Describe a Schema as a Table Schema. Datetimes whose layouts have no
+//  strptime equivalent are described as strings.
+func NewTableSchema(s *Schema) *TableSchema {
+	var t = &TableSchema{MissingValues: []string{""}}
+	if s.Null != "" {
+		t.MissingValues = append(t.MissingValues, s.Null)
+	}
+	if len(s.PrimaryKey) > 0 {
+		t.PrimaryKey = s.PrimaryKey
+	}
+	for _, col := range s.Columns {
+		var f = TableField{Name: col.Name}
+		switch col.Type {
+		case TypeInteger:
+			f.Type = "integer"
+		case TypeNumber:
+			f.Type = "number"
+		case TypeBoolean:
+			f.Type = "boolean"
+		case TypeDatetime:
+			switch col.Layout {
+			case "2006-01-02":
+				f.Type = "date"
+			case "15:04:05":
+				f.Type = "time"
+			case "", time.RFC3339:
+				f.Type = "datetime"
+			default:
+				var format, ok = layoutStrptime(col.Layout)
+				if f.Type, f.Format = "datetime", format; !ok {
+					f.Type, f.Format = "string", ""
+				}
+			}
+		default:
+			f.Type = "string"
+		}
+		var c = &TableConstraints{
+			Required:  col.Required,
+			Unique:    col.Unique,
+			Pattern:   col.Pattern,
+			MaxLength: col.MaxLength}
+		for _, v := range col.Values {
+			var x, err = strconv.ParseFloat(v, 64)
+			if err == nil && (col.Type == TypeInteger || col.Type == TypeNumber) {
+				c.Enum = append(c.Enum, x)
+			} else {
+				c.Enum = append(c.Enum, v)
+			}
+		}
+		if col.Minimum != nil {
+			c.Minimum = *col.Minimum
+		}
+		if col.Maximum != nil {
+			c.Maximum = *col.Maximum
+		}
+		if c.Required || c.Unique || c.Pattern != "" || c.MaxLength > 0 ||
+			c.Enum != nil || c.Minimum != nil || c.Maximum != nil {
+			f.Constraints = c
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	return t
+}
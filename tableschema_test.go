@@ -0,0 +1,116 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDataPackage = `{
+  "name": "orders",
+  "resources": [{
+    "name": "orders",
+    "path": "orders.csv",
+    "dialect": {"delimiter": ";", "quoteChar": "\"", "header": true, "commentChar": "#"},
+    "schema": {
+      "fields": [
+        {"name": "id", "type": "integer", "constraints": {"minimum": 1}},
+        {"name": "day", "type": "date", "format": "%d/%m/%Y"},
+        {"name": "status", "type": "string", "constraints": {"enum": ["open", "closed"]}},
+        {"name": "qty", "type": "integer", "constraints": {"enum": [1, 2, 3]}},
+        {"name": "place", "type": "geopoint"}
+      ],
+      "primaryKey": "id",
+      "missingValues": ["", "NA"]
+    }
+  }]
+}`
+
+func TestDataPackage(T *testing.T) {
+	var p, err = ReadDataPackage(strings.NewReader(testDataPackage))
+	if err != nil {
+		T.Fatal(err)
+	}
+	var res = p.Resource("orders")
+	if res == nil {
+		T.Fatal("Resource not found")
+	}
+	var config *Config
+	if config, err = res.Config(); err != nil {
+		T.Fatal(err)
+	}
+	if config.Sep != ';' || !config.HasHeader || !config.Comments || config.Null != "NA" {
+		T.Errorf("Unexpected config %+v", config)
+	}
+	var s *Schema
+	if s, err = res.Schema.Schema(); err != nil {
+		T.Fatal(err)
+	}
+	var one = 1.0
+	var expect = []SchemaColumn{
+		{Name: "id", Type: TypeInteger, Minimum: &one},
+		{Name: "day", Type: TypeDatetime, Layout: "02/01/2006"},
+		{Name: "status", Type: TypeString, Values: []string{"open", "closed"}},
+		{Name: "qty", Type: TypeInteger, Values: []string{"1", "2", "3"}},
+		{Name: "place", Type: TypeString},
+	}
+	if !reflect.DeepEqual(s.Columns, expect) || !equalStrings(s.PrimaryKey, []string{"id"}) {
+		T.Errorf("Unexpected schema %+v", s)
+	}
+
+	var v, _ = NewValidator(s)
+	v.CollectAll = true
+	var input = "# orders\nid;day;status;qty;place\n1;31/12/2011;open;2;x\n1;2011-12-31;NA;4;y\n"
+	var violations, _ = v.Validate(StringReader(input, config)).(ValidationError)
+	if len(violations) != 3 {
+		T.Errorf("Unexpected violations %v", violations)
+	}
+
+	// Write and read back the descriptor.
+	var out = &DataPackage{Name: "out", Resources: []DataResource{NewDataResource("orders", "orders.csv", s, config)}}
+	var buf bytes.Buffer
+	if err = out.WriteJSON(&buf); err != nil {
+		T.Fatal(err)
+	}
+	if p, err = ReadDataPackage(&buf); err != nil {
+		T.Fatal(err)
+	}
+	var s2 *Schema
+	if s2, err = p.Resources[0].Schema.Schema(); err != nil {
+		T.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, s2) {
+		T.Errorf("Unexpected schema %+v (!= %+v)", s2, s)
+	}
+	var config2, _ = p.Resources[0].Config()
+	if *config2 != *config {
+		T.Errorf("Unexpected config %+v (!= %+v)", config2, config)
+	}
+}
+
+func TestTableSchemaUnsupported(T *testing.T) {
+	for _, desc := range []string{
+		`{"dialect": {"quoteChar": "'"}}`,
+		`{"dialect": {"delimiter": ";;"}}`,
+		`{"schema": {"fields": [], "missingValues": ["NA", "-"]}}`,
+		`{"schema": {"fields": [{"name": "d", "type": "date", "format": "%j"}]}}`,
+	} {
+		var res DataResource
+		var p, err = ReadDataPackage(strings.NewReader(`{"resources": [` + desc + `]}`))
+		if err != nil {
+			T.Fatal(err)
+		}
+		res = p.Resources[0]
+		if _, err = res.Config(); err == nil && res.Schema != nil {
+			_, err = res.Schema.Schema()
+		}
+		if _, ok := err.(*UnsupportedError); !ok {
+			T.Errorf("Unexpected error %v for %s", err, desc)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+/*
+*  File: validate.go
+*  Description: Checking CSV data against a Schema.
+ */
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//  Reasons a field violates the constraints of its column. Fields that do
+//  not have the column's type are reported with the error from parsing.
+var (
+	ErrorRequired   = errors.New("Field is required.")
+	ErrorNotAllowed = errors.New("Value is not allowed.")
+	ErrorRange      = errors.New("Value is out of range.")
+	ErrorPattern    = errors.New("Value does not match pattern.")
+	ErrorTooLong    = errors.New("Value is too long.")
+	ErrorNotUnique  = errors.New("Value is not unique.")
+)
+
+//  The violations found by a Validator collecting all of them.
+type ValidationError []*LineError
+
+func (e ValidationError) Error() string {
+	switch len(e) {
+	case 0:
+		return "no violations"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more violations)", e[0], len(e)-1)
+}
+
+//  Checks the rows of CSV data against a Schema.
+type Validator struct {
+	schema   *Schema
+	patterns []*regexp.Regexp
+	//  Collect every violation, instead of stopping at the first.
+	CollectAll bool
+}
+
+//  Create a Validator for a schema, compiling the patterns of its columns.
+//  The Validator stops at the first violation unless CollectAll is set.
+func NewValidator(s *Schema) (*Validator, error) {
+	var v = &Validator{schema: s, patterns: make([]*regexp.Regexp, len(s.Columns))}
+	for i, col := range s.Columns {
+		if col.Pattern == "" {
+			continue
+		}
+		var err error
+		if v.patterns[i], err = regexp.Compile("^(?:" + col.Pattern + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+//  Check the rows read from r. When r has a header, schema columns are found
+//  by name (every one must be present) and other columns are ignored.
+//  Otherwise columns are matched by position. Violations are returned as a
+//  *LineError, or as a ValidationError when collecting all of them. Errors
+//  reading r are returned as they are. The values of unique columns and
+//  primary keys are held in memory. Duplicate primary keys are reported
+//  with ErrorDuplicateKey, in a column naming every key column.
+func (v *Validator) Validate(r *Reader) error {
+	var header, err = r.Header()
+	if err != nil {
+		return err
+	}
+	var (
+		indices = make([]int, len(v.schema.Columns))
+		seen    = make([]map[string]bool, len(v.schema.Columns))
+		keys    []int
+		keySeen = make(map[string]bool)
+		keyName = strings.Join(v.schema.PrimaryKey, ",")
+	)
+	for i, col := range v.schema.Columns {
+		indices[i] = i
+		if header != nil {
+			if indices[i] = header.Index(col.Name); indices[i] < 0 {
+				return &ColumnError{col.Name}
+			}
+		}
+		if col.Unique {
+			seen[i] = make(map[string]bool)
+		}
+	}
+	for _, name := range v.schema.PrimaryKey {
+		var i = v.schema.Index(name)
+		if i < 0 {
+			return &ColumnError{name}
+		}
+		keys = append(keys, indices[i])
+	}
+	var violations ValidationError
+	var violation = func(line int, column string, err error) bool {
+		var lerr = &LineError{Line: line, Column: column, Err: err}
+		violations = append(violations, lerr)
+		return v.CollectAll
+	}
+	for row := r.ReadRow(); !row.HasEOF(); row = r.ReadRow() {
+		if row.HasError() {
+			return row.Error
+		}
+		for i, j := range indices {
+			var field = fieldOr(row.Fields, j, v.schema.Null)
+			err = v.check(i, field)
+			if err == nil && seen[i] != nil && !v.isNull(field) {
+				if seen[i][field] {
+					err = ErrorNotUnique
+				}
+				seen[i][field] = true
+			}
+			if err != nil && !violation(row.Line, v.schema.Columns[i].Name, err) {
+				return violations[0]
+			}
+		}
+		if keys != nil {
+			var key = strings.Join(projectFields(row.Fields, keys, v.schema.Null), "\x00")
+			if keySeen[key] && !violation(row.Line, keyName, ErrorDuplicateKey) {
+				return violations[0]
+			}
+			keySeen[key] = true
+		}
+	}
+	if violations != nil {
+		return violations
+	}
+	return nil
+}
+
+//  Check a field of the ith column of the schema.
+func (v *Validator) check(i int, field string) error {
+	var col = &v.schema.Columns[i]
+	if v.isNull(field) {
+		if col.Required || containsString(v.schema.PrimaryKey, col.Name) {
+			return ErrorRequired
+		}
+		return nil
+	}
+	var value, err = col.Parse(field)
+	if err != nil {
+		return err
+	}
+	if col.Values != nil && !containsString(col.Values, field) {
+		return ErrorNotAllowed
+	}
+	var x float64
+	switch value := value.(type) {
+	case int64:
+		x = float64(value)
+	case float64:
+		x = value
+	}
+	if col.Type == TypeInteger || col.Type == TypeNumber {
+		if col.Minimum != nil && x < *col.Minimum || col.Maximum != nil && x > *col.Maximum {
+			return ErrorRange
+		}
+	}
+	if v.patterns[i] != nil && !v.patterns[i].MatchString(field) {
+		return ErrorPattern
+	}
+	if col.MaxLength > 0 && utf8.RuneCountInString(field) > col.MaxLength {
+		return ErrorTooLong
+	}
+	return nil
+}
+
+func (v *Validator) isNull(field string) bool {
+	return field == "" || field == v.schema.Null
+}
@@ -0,0 +1,95 @@
+// Copyright 2011, Bryan Matsuo. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csvutil
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var testValidateSchema = `{
+	"header": true,
+	"columns": [
+		{"name": "id", "type": "integer", "required": true, "minimum": 1},
+		{"name": "code", "type": "string", "pattern": "[A-Z]{3}", "max_length": 3},
+		{"name": "status", "type": "string", "values": ["open", "closed"]},
+		{"name": "score", "type": "number", "maximum": 10}
+	]
+}`
+
+func TestValidator(T *testing.T) {
+	var s Schema
+	if err := json.Unmarshal([]byte(testValidateSchema), &s); err != nil {
+		T.Fatal(err)
+	}
+	var config = NewConfig()
+	s.Configure(config)
+	var input = "score,status,code,id,extra\n" +
+		"1.5,open,ABC,1,x\n" + // line 2: ok
+		"11,open,ABC,2,x\n" + // line 3: score out of range
+		"2,pending,AB,,x\n" + // line 4: status, code and id
+		"x,,ABC,0,x\n" // line 5: score and id
+
+	var v, err = NewValidator(&s)
+	if err != nil {
+		T.Fatal(err)
+	}
+	err = v.Validate(StringReader(input, config))
+	if lerr, ok := err.(*LineError); !ok || lerr.Line != 3 || lerr.Column != "score" || lerr.Err != ErrorRange {
+		T.Errorf("Unexpected fail-fast error %v", err)
+	}
+
+	v.CollectAll = true
+	err = v.Validate(StringReader(input, config))
+	var violations, ok = err.(ValidationError)
+	if !ok {
+		T.Fatalf("Unexpected error %v", err)
+	}
+	var expect = []struct {
+		line   int
+		column string
+	}{{3, "score"}, {4, "id"}, {4, "code"}, {4, "status"}, {5, "id"}, {5, "score"}}
+	if len(violations) != len(expect) {
+		T.Fatalf("Unexpected violations %v", violations)
+	}
+	for i, e := range expect {
+		if violations[i].Line != e.line || violations[i].Column != e.column {
+			T.Errorf("Unexpected violation %v", violations[i])
+		}
+	}
+	if violations[1].Err != ErrorRequired || violations[2].Err != ErrorPattern ||
+		violations[3].Err != ErrorNotAllowed || violations[4].Err != ErrorRange {
+		T.Errorf("Unexpected violations %v", violations)
+	}
+
+	if err = v.Validate(StringReader("id,code\n1,ABC\n", config)); err == nil {
+		T.Error("No error for missing columns")
+	}
+	s.Columns[1].Pattern = "("
+	if _, err = NewValidator(&s); err == nil {
+		T.Error("No error for invalid pattern")
+	}
+}
+
+func TestValidatorUnique(T *testing.T) {
+	var s = &Schema{
+		HasHeader: true,
+		Columns: []SchemaColumn{
+			{Name: "a", Type: TypeInteger},
+			{Name: "b", Type: TypeString, Unique: true}},
+		PrimaryKey: []string{"a"}}
+	var v, _ = NewValidator(s)
+	v.CollectAll = true
+	var config = NewConfig()
+	s.Configure(config)
+	var err = v.Validate(StringReader("a,b\n1,x\n2,y\n1,\n,y\n", config))
+	var violations, ok = err.(ValidationError)
+	if !ok || len(violations) != 3 ||
+		violations[0].Line != 4 || violations[0].Err != ErrorDuplicateKey ||
+		violations[1].Line != 5 || violations[1].Err != ErrorRequired ||
+		violations[2].Line != 5 || violations[2].Err != ErrorNotUnique {
+		T.Errorf("Unexpected violations %v", err)
+	}
+}